@@ -1,21 +1,59 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"gopilot/internal/actions"
+	"gopilot/internal/agent"
 	"gopilot/internal/chat"
+	"gopilot/internal/commands"
 	"gopilot/internal/config"
+	"gopilot/internal/providers"
+	"gopilot/internal/schema"
 )
 
 // Version is set during build via ldflags
 var Version string
 
+// Exit codes used when a request is cut short, following shell conventions
+const (
+	exitInterrupted = 130
+	exitTimeout     = 124
+)
+
+const (
+	// exitBudgetExceeded means the request was not sent because it would exceed a budget limit
+	exitBudgetExceeded = 3
+	// exitSchemaInvalid means the response still did not match the schema after every repair attempt
+	exitSchemaInvalid = 4
+)
+
 func main() {
+	// Cancel in-flight requests on Ctrl-C or when a CI runner terminates the job
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Dispatch subcommands such as `gopilot models` before parsing prompt
+	// flags. A prompt equal to a command name is sent with `gopilot -- NAME`,
+	// which flag.Parse below takes as the end of the leading flags.
+	if len(os.Args) > 1 {
+		if command, exists := commands.Get(os.Args[1]); exists {
+			if err := command(ctx, os.Args[2:]); err != nil {
+				exitWithError(ctx, err)
+			}
+			os.Exit(0)
+		}
+	}
+
 	// Define flags
 	versionFlag := flag.Bool("version", false, "Show version information")
 	vFlag := flag.Bool("v", false, "Show version information (shorthand)")
@@ -30,6 +68,41 @@ func main() {
 	configFlag := flag.String("config", "", "Configuration file path")
 	cFlag := flag.String("c", "", "Configuration file path (shorthand)")
 	actionFlag := flag.String("action", "", "Specify an action to process the input/output")
+	timeoutFlag := flag.Duration("timeout", 0, "Abort the request after this long, e.g. 30s or 2m (0 disables)")
+	usageFlag := flag.Bool("usage", false, "Print token usage and estimated cost to stderr")
+	maxIterationsFlag := flag.Int("max-iterations", 0, "Maximum requests an action with tools may make (default 10)")
+	transcriptFlag := flag.String("transcript", "", "Write each step of an action with tools to this file as JSON lines")
+	schemaFlag := flag.String("schema", "", "JSON Schema file the response must match")
+	maxContextFlag := flag.Int("max-context-tokens", 0, "Maximum tokens of history sent with the prompt (default: the model's context window)")
+	sessionFlag := flag.String("session", "", "Name of the conversation to use (default \"default\")")
+
+	// Generation parameters override the config only when given
+	var sampling providers.Sampling
+	flag.Func("temperature", "Sampling temperature, 0 for the most deterministic output", func(value string) error {
+		temperature, err := strconv.ParseFloat(value, 64)
+		sampling.Temperature = &temperature
+		return err
+	})
+	flag.Func("top-p", "Nucleus sampling probability mass", func(value string) error {
+		topP, err := strconv.ParseFloat(value, 64)
+		sampling.TopP = &topP
+		return err
+	})
+	flag.Func("seed", "Seed for reproducible sampling, where the provider supports it", func(value string) error {
+		seed, err := strconv.Atoi(value)
+		sampling.Seed = &seed
+		return err
+	})
+	flag.IntVar(&sampling.MaxTokens, "max-tokens", 0, "Maximum tokens to generate")
+	flag.Func("stop", "Stop sequence; may be repeated", func(value string) error {
+		sampling.Stop = append(sampling.Stop, value)
+		return nil
+	})
+	var images []string
+	flag.Func("image", "Image file to attach to the prompt; may be repeated", func(value string) error {
+		images = append(images, value)
+		return nil
+	})
 
 	flag.Parse()
 
@@ -50,8 +123,10 @@ func main() {
 	}
 	prompt := args[0]
 
-	// Remove prompt from args to parse remaining flags
-	os.Args = append([]string{args[0]}, args[1:]...)
+	// Parse the flags that follow the prompt
+	if err := flag.CommandLine.Parse(args[1:]); err != nil {
+		os.Exit(2)
+	}
 
 	// Use shorthand flag if main flag is empty
 	if *configFlag == "" && *cFlag != "" {
@@ -66,7 +141,19 @@ func main() {
 	}
 
 	// Create chat session
-	session := chat.NewSession(cfg)
+	session, err := chat.NewSession(cfg, *sessionFlag)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if backup := session.CorruptBackup(); backup != "" {
+		fmt.Fprintf(os.Stderr, "Warning: session history could not be read and was moved to %s; starting a new conversation\n", backup)
+	}
+	// Cleared before context files and the action's system prompt are added,
+	// so they are part of the new conversation
+	if *newFlag || *nFlag {
+		session.NewChat()
+	}
 
 	// Check if prompt is a file path
 	if _, err := os.Stat(prompt); err == nil {
@@ -99,15 +186,41 @@ func main() {
 				fmt.Printf("Error reading context file %s: %v\n", file, err)
 				continue
 			}
-			session.AddContext(string(content))
+			session.AddContextFile(file, content)
 		}
 	}
 
 	// Configure session options
 	opts := chat.Options{
-		Stream:  *streamFlag || *sFlag,
-		NewChat: *newFlag || *nFlag,
-		OneShot: *oneShotFlag || *oFlag,
+		OneShot:          *oneShotFlag || *oFlag,
+		Action:           *actionFlag,
+		Sampling:         sampling,
+		MaxContextTokens: *maxContextFlag,
+	}
+	if *streamFlag || *sFlag {
+		opts.Sink = providers.WriterSink(os.Stdout)
+	}
+
+	for _, path := range images {
+		image, err := providers.ReadImage(path)
+		if err != nil {
+			fmt.Printf("Error reading image: %v\n", err)
+			os.Exit(1)
+		}
+		opts.Images = append(opts.Images, image)
+	}
+
+	// The flag takes precedence over the action's configured schema
+	schemaPath := *schemaFlag
+	if schemaPath == "" {
+		schemaPath = cfg.Actions[*actionFlag].Schema
+	}
+	if schemaPath != "" {
+		opts.Schema, err = schema.Load(schemaPath)
+		if err != nil {
+			fmt.Printf("Error loading schema: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
 	// Apply action if specified
@@ -122,20 +235,53 @@ func main() {
 
 	// Modify input and history if action exists
 	if activeAction != nil {
+		if configurable, ok := activeAction.(actions.ConfigurableAction); ok {
+			if err := configurable.Configure(cfg); err != nil {
+				fmt.Printf("Error configuring action: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
 		var err error
-		input, session.history, err = activeAction.PreHook(input, session.formatHistoryForProvider())
+		var history []providers.Message
+		input, history, err = activeAction.PreHook(input, session.FormatHistoryForProvider())
 		if err != nil {
 			fmt.Printf("Error in action pre-hook: %v\n", err)
 			os.Exit(1)
 		}
+		session.SetHistory(history)
 	}
 
-	// Get response
-	response, err := session.Send(input, opts)
+	if *timeoutFlag > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeoutFlag)
+		defer cancel()
+	}
+
+	// Get response, looping over tool calls for actions that expose tools
+	var reply *chat.Reply
+	if toolAction, ok := activeAction.(actions.ToolAction); ok {
+		agentOpts := agent.Options{MaxIterations: cfg.Agent.MaxIterations}
+		if *maxIterationsFlag > 0 {
+			agentOpts.MaxIterations = *maxIterationsFlag
+		}
+		if *transcriptFlag != "" {
+			transcript, err := os.Create(*transcriptFlag)
+			if err != nil {
+				fmt.Printf("Error creating transcript: %v\n", err)
+				os.Exit(1)
+			}
+			defer transcript.Close()
+			agentOpts.Transcript = transcript
+		}
+		reply, err = agent.Run(ctx, session, input, opts, toolAction, agentOpts)
+	} else {
+		reply, err = session.Send(ctx, input, opts)
+	}
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		exitWithError(ctx, err)
 	}
+	response := reply.Content
 
 	// Process response if action exists
 	if activeAction != nil {
@@ -147,5 +293,53 @@ func main() {
 		}
 	}
 
-	fmt.Println(response)
+	// Streamed responses have already been written by the sink, so they are
+	// only printed again, after the stream, when the action changed them
+	if opts.Sink == nil || response != reply.Content {
+		fmt.Println(response)
+	}
+
+	// Usage goes to stderr so it never mixes with piped output
+	if *usageFlag {
+		printUsage(reply)
+	}
+}
+
+func printUsage(reply *chat.Reply) {
+	estimated := ""
+	if reply.Usage.Estimated {
+		estimated = " (estimated)"
+	}
+	cost := "unknown cost"
+	if reply.Priced {
+		cost = fmt.Sprintf("~$%.6f", reply.Cost)
+	}
+	fmt.Fprintf(os.Stderr, "Usage: %d prompt + %d completion = %d tokens%s, %s\n",
+		reply.Usage.PromptTokens, reply.Usage.CompletionTokens, reply.Usage.TotalTokens(), estimated, cost)
+}
+
+// exitWithError reports err and exits, distinguishing interrupts and
+// timeouts from other failures so pipelines can tell them apart
+func exitWithError(ctx context.Context, err error) {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		fmt.Println("Error: request timed out")
+		os.Exit(exitTimeout)
+	case errors.Is(ctx.Err(), context.Canceled):
+		fmt.Println("Error: request cancelled")
+		os.Exit(exitInterrupted)
+	}
+
+	var budgetErr *chat.BudgetError
+	if errors.As(err, &budgetErr) {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitBudgetExceeded)
+	}
+	var schemaErr *chat.SchemaError
+	if errors.As(err, &schemaErr) {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitSchemaInvalid)
+	}
+	fmt.Printf("Error: %v\n", err)
+	os.Exit(1)
 }
@@ -3,6 +3,6 @@ module gopilot
 go 1.22.3
 
 require (
-	github.com/sashabaranov/go-openai v1.17.9
+	github.com/sashabaranov/go-openai v1.32.5
 	gopkg.in/yaml.v2 v2.4.0
 )
@@ -1,6 +1,11 @@
 package actions
 
-import "gopilot/internal/providers"
+import (
+	"context"
+
+	"gopilot/internal/config"
+	"gopilot/internal/providers"
+)
 
 type Action interface {
 	// PreHook modifies or enhances the input before it's sent to the provider
@@ -10,6 +15,25 @@ type Action interface {
 	PostHook(response string) (string, error)
 }
 
+// ToolAction is implemented by actions that expose functions the model can call
+type ToolAction interface {
+	Action
+
+	// Tools returns the functions offered to the model
+	Tools() []providers.Tool
+
+	// CallTool runs a call made by the model and returns the result sent back to it
+	CallTool(ctx context.Context, call providers.ToolCall) (string, error)
+}
+
+// ConfigurableAction is implemented by actions that read settings from the configuration
+type ConfigurableAction interface {
+	Action
+
+	// Configure is called with the loaded configuration before the action runs
+	Configure(cfg *config.Config) error
+}
+
 // Registry stores all available actions
 var registry = make(map[string]Action)
 
@@ -0,0 +1,59 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gopilot/internal/agent"
+	"gopilot/internal/config"
+	"gopilot/internal/providers"
+)
+
+func init() {
+	Register("agent", &AgentAction{toolbox: agent.NewToolbox()})
+}
+
+// AgentAction lets the model work on a task in several steps, reading files,
+// listing directories, searching and running allow-listed commands until it
+// can give a final answer.
+type AgentAction struct {
+	toolbox *agent.Toolbox
+}
+
+func (a *AgentAction) Configure(cfg *config.Config) error {
+	a.toolbox.AllowedCommands = cfg.Agent.AllowedCommands
+	if cfg.Agent.CommandTimeout != "" {
+		timeout, err := time.ParseDuration(cfg.Agent.CommandTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid AGENT COMMAND_TIMEOUT: %w", err)
+		}
+		a.toolbox.CommandTimeout = timeout
+	}
+	return nil
+}
+
+func (a *AgentAction) PreHook(input interface{}, history []providers.Message) (interface{}, []providers.Message, error) {
+	systemMsg := providers.Message{
+		Role: "system",
+		Content: providers.TextContent(`You are an engineering agent working in a repository. To complete the task:
+1. Use the tools to inspect files and, where allowed, run commands; never guess at file contents
+2. Work in small steps and check the result of each one
+3. When you are done, reply without calling a tool, giving the final answer and any changes to make`),
+	}
+
+	newHistory := append([]providers.Message{systemMsg}, history...)
+	return input, newHistory, nil
+}
+
+func (a *AgentAction) PostHook(response string) (string, error) {
+	return response, nil
+}
+
+func (a *AgentAction) Tools() []providers.Tool {
+	return a.toolbox.Tools()
+}
+
+func (a *AgentAction) CallTool(ctx context.Context, call providers.ToolCall) (string, error) {
+	return a.toolbox.CallTool(ctx, call)
+}
@@ -14,13 +14,19 @@ func (a *EditCodeAction) PreHook(input interface{}, history []providers.Message)
 	// Add system message to guide the model's response format
 	systemMsg := providers.Message{
 		Role: "system",
-		Content: `You are a code editor. When asked to make changes to code:
+		Content: providers.TextContent(`You are a code editor. When asked to make changes to code:
 1. Analyze the requested changes
 2. Respond with only the modified code sections
 3. Use ... to indicate unchanged code
-4. Include brief comments explaining the changes`,
+4. Include brief comments explaining the changes`),
 	}
 
+	// The prompt is kept in the session, so it is only added once
+	for _, msg := range history {
+		if msg.Role == systemMsg.Role && msg.Content.Text() == systemMsg.Content.Text() {
+			return input, history, nil
+		}
+	}
 	newHistory := append([]providers.Message{systemMsg}, history...)
 	return input, newHistory, nil
 }
@@ -0,0 +1,29 @@
+package actions
+
+import (
+	"testing"
+
+	"gopilot/internal/providers"
+)
+
+func TestEditCodePromptIsAddedOnce(t *testing.T) {
+	action := &EditCodeAction{}
+	history := []providers.Message{{Role: "user", Content: providers.TextContent("Rename x")}}
+
+	_, history, err := action.PreHook("Rename y", history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Role != "system" {
+		t.Fatalf("history = %+v, want the prompt before it", history)
+	}
+
+	// The next run sees the prompt in the session's history
+	_, again, err := action.PreHook("Rename z", history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 2 {
+		t.Errorf("history has %d messages after a second run, want the prompt once", len(again))
+	}
+}
@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"gopilot/internal/chat"
+	"gopilot/internal/providers"
+)
+
+// DefaultMaxIterations bounds the loop when no limit is configured
+const DefaultMaxIterations = 10
+
+// Executor offers tools to the model and runs the calls it makes.
+// actions.ToolAction satisfies it.
+type Executor interface {
+	Tools() []providers.Tool
+	CallTool(ctx context.Context, call providers.ToolCall) (string, error)
+}
+
+// Options configures a run of the loop
+type Options struct {
+	// MaxIterations is the most requests sent before giving up
+	MaxIterations int
+	// Transcript receives every step as a line of JSON as it happens; may be nil
+	Transcript io.Writer
+}
+
+// Step is one entry in the transcript of a run
+type Step struct {
+	Time      time.Time `json:"time"`
+	Iteration int       `json:"iteration"`
+	// Type is "assistant" for model output, "tool_call" and "tool_result" for tool use
+	Type      string `json:"type"`
+	Content   string `json:"content,omitempty"`
+	Tool      string `json:"tool,omitempty"`
+	CallID    string `json:"call_id,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// MaxIterationsError is returned when the model is still calling tools
+// after the iteration limit
+type MaxIterationsError struct {
+	Iterations int
+}
+
+func (e *MaxIterationsError) Error() string {
+	return fmt.Sprintf("agent stopped after %d iterations without a final answer", e.Iterations)
+}
+
+// Run sends input and keeps answering the model's tool calls with executor
+// until it replies without calling a tool. The returned reply holds the
+// final answer with the usage and cost of every request in the run.
+func Run(ctx context.Context, session *chat.Session, input interface{}, opts chat.Options, executor Executor, agentOpts Options) (*chat.Reply, error) {
+	maxIterations := agentOpts.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxIterations
+	}
+	opts.Tools = executor.Tools()
+
+	record := func(step Step) {
+		if agentOpts.Transcript == nil {
+			return
+		}
+		step.Time = time.Now()
+		if data, err := json.Marshal(step); err == nil {
+			agentOpts.Transcript.Write(append(data, '\n'))
+		}
+	}
+
+	var total chat.Reply
+	total.Priced = true
+	for iteration := 1; iteration <= maxIterations; iteration++ {
+		reply, err := session.Send(ctx, input, opts)
+		if err != nil {
+			return nil, err
+		}
+		total.Usage.PromptTokens += reply.Usage.PromptTokens
+		total.Usage.CompletionTokens += reply.Usage.CompletionTokens
+		total.Usage.Estimated = total.Usage.Estimated || reply.Usage.Estimated
+		total.Cost += reply.Cost
+		total.Priced = total.Priced && reply.Priced
+
+		if reply.Content != "" {
+			record(Step{Iteration: iteration, Type: "assistant", Content: reply.Content})
+		}
+		if len(reply.ToolCalls) == 0 {
+			total.Content = reply.Content
+			return &total, nil
+		}
+
+		for _, call := range reply.ToolCalls {
+			record(Step{Iteration: iteration, Type: "tool_call", Tool: call.Name, CallID: call.ID, Arguments: call.Arguments})
+
+			// Tool failures are reported to the model so it can recover, but
+			// cancellation ends the run
+			result, err := executor.CallTool(ctx, call)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			step := Step{Iteration: iteration, Type: "tool_result", Tool: call.Name, CallID: call.ID, Content: result}
+			if err != nil {
+				step.Error = err.Error()
+				result = "error: " + err.Error()
+			}
+			record(step)
+			session.AddToolResult(call.ID, result)
+		}
+		input = nil
+		// The history was cleared for the first request only
+		opts.NewChat = false
+	}
+	return nil, &MaxIterationsError{Iterations: maxIterations}
+}
@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"gopilot/internal/chat"
+	"gopilot/internal/config"
+	"gopilot/internal/providers"
+)
+
+func init() {
+	providers.Register("scripted", nil, func(opts providers.Options) (providers.Provider, error) {
+		return &scriptedProvider{}, nil
+	})
+}
+
+// script answers the requests made to the scripted provider
+var script func(request providers.Request) (*providers.Response, error)
+
+type scriptedProvider struct{}
+
+func (p *scriptedProvider) Send(ctx context.Context, request providers.Request, sink providers.StreamSink) (*providers.Response, error) {
+	return script(request)
+}
+
+func (p *scriptedProvider) SupportsStreaming() bool { return false }
+
+func (p *scriptedProvider) HandleRateLimiting(err error) error { return err }
+
+// echoExecutor answers every tool call with the call's arguments
+type echoExecutor struct{}
+
+func (echoExecutor) Tools() []providers.Tool {
+	return []providers.Tool{{Name: "echo"}}
+}
+
+func (echoExecutor) CallTool(ctx context.Context, call providers.ToolCall) (string, error) {
+	return call.Arguments, nil
+}
+
+func TestRunStartsNewChatOnce(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	session, err := chat.NewSession(&config.Config{Provider: "scripted", Model: "scripted"}, "agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	script = func(providers.Request) (*providers.Response, error) {
+		return &providers.Response{Content: "earlier answer"}, nil
+	}
+	if _, err := session.Send(context.Background(), "earlier question", chat.Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := 0
+	script = func(request providers.Request) (*providers.Response, error) {
+		requests++
+		if requests == 1 {
+			if len(request.History) != 0 {
+				t.Errorf("first request history = %v, want the new chat to start empty", request.History)
+			}
+			return &providers.Response{ToolCalls: []providers.ToolCall{{ID: "call_1", Name: "echo", Arguments: `{"text":"hi"}`}}}, nil
+		}
+		// The tool call and its result are sent back rather than cleared again
+		if len(request.History) != 3 || request.Message != nil {
+			t.Errorf("second request history = %v, message %v, want the question, tool call and result", request.History, request.Message)
+		}
+		return &providers.Response{Content: "done"}, nil
+	}
+
+	reply, err := Run(context.Background(), session, "new question", chat.Options{NewChat: true}, echoExecutor{}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Content != "done" || requests != 2 {
+		t.Fatalf("reply = %q after %d requests, want done after 2", reply.Content, requests)
+	}
+
+	history := session.GetHistory()
+	roles := make([]string, len(history))
+	for i, msg := range history {
+		roles[i] = msg.Role
+	}
+	if len(history) != 4 || history[0].Content.Text() != "new question" || history[3].Content.Text() != "done" {
+		t.Errorf("history roles = %v, want the new question, tool call, result and answer", roles)
+	}
+}
@@ -0,0 +1,311 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopilot/internal/providers"
+)
+
+const (
+	// maxOutput bounds what a single tool result sends back to the model
+	maxOutput = 32 * 1024
+	// maxGrepMatches bounds the lines returned by grep
+	maxGrepMatches = 200
+	// DefaultCommandTimeout bounds a run_command call when no timeout is configured
+	DefaultCommandTimeout = 2 * time.Minute
+)
+
+// Toolbox provides the built-in tools: reading files, listing directories,
+// searching with a regular expression and running allow-listed commands.
+// Paths are confined to Root.
+type Toolbox struct {
+	Root string
+	// AllowedCommands are the command prefixes run_command accepts, e.g.
+	// "go test"; run_command is not offered when it is empty
+	AllowedCommands []string
+	CommandTimeout  time.Duration
+}
+
+// NewToolbox returns a toolbox rooted at the working directory
+func NewToolbox() *Toolbox {
+	root, err := os.Getwd()
+	if err != nil {
+		root = "."
+	}
+	return &Toolbox{Root: root, CommandTimeout: DefaultCommandTimeout}
+}
+
+// Tools returns the definitions of the built-in tools
+func (t *Toolbox) Tools() []providers.Tool {
+	tools := []providers.Tool{
+		{
+			Name:        "read_file",
+			Description: "Read a text file from the workspace.",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"path":{"type":"string","description":"Path relative to the workspace root"}},"required":["path"]}`),
+		},
+		{
+			Name:        "list_dir",
+			Description: "List the entries of a workspace directory. Directories end with a slash.",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"path":{"type":"string","description":"Path relative to the workspace root, defaults to the root"}}}`),
+		},
+		{
+			Name:        "grep",
+			Description: "Search workspace files for lines matching a regular expression (RE2 syntax). Returns path:line: text for each match.",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"pattern":{"type":"string"},"path":{"type":"string","description":"File or directory to search, defaults to the root"}},"required":["pattern"]}`),
+		},
+	}
+	if len(t.AllowedCommands) > 0 {
+		tools = append(tools, providers.Tool{
+			Name:        "run_command",
+			Description: "Run a command in the workspace root and return its exit code and output. Only commands starting with one of these are allowed: " + strings.Join(t.AllowedCommands, "; "),
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"command":{"type":"string","description":"The command line, without shell syntax"}},"required":["command"]}`),
+		})
+	}
+	return tools
+}
+
+// CallTool runs a tool call made by the model
+func (t *Toolbox) CallTool(ctx context.Context, call providers.ToolCall) (string, error) {
+	var args struct {
+		Path    string `json:"path"`
+		Pattern string `json:"pattern"`
+		Command string `json:"command"`
+	}
+	if call.Arguments != "" {
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+	}
+
+	switch call.Name {
+	case "read_file":
+		return t.readFile(args.Path)
+	case "list_dir":
+		return t.listDir(args.Path)
+	case "grep":
+		return t.grep(args.Pattern, args.Path)
+	case "run_command":
+		return t.runCommand(ctx, args.Command)
+	}
+	return "", fmt.Errorf("unknown tool: %s", call.Name)
+}
+
+// resolve maps a workspace path onto the filesystem, rejecting paths that escape the root
+func (t *Toolbox) resolve(path string) (string, error) {
+	root, err := filepath.Abs(t.Root)
+	if err != nil {
+		return "", err
+	}
+	resolved := path
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(root, resolved)
+	}
+	resolved = filepath.Clean(resolved)
+
+	// Follow symlinks so a link cannot point outside the workspace
+	if target, err := filepath.EvalSymlinks(resolved); err == nil {
+		if realRoot, err := filepath.EvalSymlinks(root); err == nil {
+			root, resolved = realRoot, target
+		}
+	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q is outside the workspace", path)
+	}
+	return resolved, nil
+}
+
+func (t *Toolbox) readFile(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("path is required")
+	}
+	resolved, err := t.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		return "", err
+	}
+	return truncate(string(data)), nil
+}
+
+func (t *Toolbox) listDir(path string) (string, error) {
+	resolved, err := t.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(resolved)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	for _, entry := range entries {
+		out.WriteString(entry.Name())
+		if entry.IsDir() {
+			out.WriteString("/")
+		}
+		out.WriteString("\n")
+	}
+	return truncate(out.String()), nil
+}
+
+func (t *Toolbox) grep(pattern, path string) (string, error) {
+	if pattern == "" {
+		return "", errors.New("pattern is required")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+	resolved, err := t.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	root, err := t.resolve("")
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	matches := 0
+	errLimit := errors.New("match limit reached")
+	err = filepath.WalkDir(resolved, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if name := entry.Name(); file != resolved && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Symlinks are skipped, as reading one follows it and it may point
+		// outside the workspace, and so are devices and pipes
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil || bytes.IndexByte(data, 0) >= 0 {
+			// Unreadable and binary files are skipped
+			return nil
+		}
+		rel, _ := filepath.Rel(root, file)
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if !re.Match(scanner.Bytes()) {
+				continue
+			}
+			fmt.Fprintf(&out, "%s:%d: %s\n", filepath.ToSlash(rel), line, scanner.Text())
+			if matches++; matches >= maxGrepMatches {
+				return errLimit
+			}
+		}
+		return nil
+	})
+	if err != nil && err != errLimit {
+		return "", err
+	}
+
+	if matches == 0 {
+		return "no matches", nil
+	}
+	if err == errLimit {
+		fmt.Fprintf(&out, "[stopped after %d matches]\n", maxGrepMatches)
+	}
+	return truncate(out.String()), nil
+}
+
+// runCommand runs an allow-listed command without a shell, so arguments
+// cannot chain further commands
+func (t *Toolbox) runCommand(ctx context.Context, command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", errors.New("command is required")
+	}
+	if !t.allowed(args) {
+		return "", fmt.Errorf("command not allowed: %q. Allowed commands start with: %s", command, strings.Join(t.AllowedCommands, "; "))
+	}
+
+	timeout := t.CommandTimeout
+	if timeout <= 0 {
+		timeout = DefaultCommandTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = t.Root
+	output, err := cmd.CombinedOutput()
+
+	exitCode := 0
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return "", fmt.Errorf("command timed out after %s", timeout)
+	case errors.As(err, &exitErr):
+		exitCode = exitErr.ExitCode()
+	case err != nil:
+		return "", err
+	}
+	return truncate(fmt.Sprintf("exit code: %d\n%s", exitCode, output)), nil
+}
+
+// execFlags name the flags that have an allowed command run another program,
+// such as go test -exec, which would get around the allow-list
+var execFlags = map[string]bool{"exec": true, "toolexec": true}
+
+// allowed reports whether the command's words start with an allow-listed
+// prefix and pass no flag running another program
+func (t *Toolbox) allowed(args []string) bool {
+	for _, arg := range args[1:] {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if execFlags[name] {
+			return false
+		}
+	}
+	for _, allowed := range t.AllowedCommands {
+		prefix := strings.Fields(allowed)
+		if len(prefix) == 0 || len(prefix) > len(args) {
+			continue
+		}
+		match := true
+		for i, word := range prefix {
+			if args[i] != word {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func truncate(text string) string {
+	if len(text) <= maxOutput {
+		return text
+	}
+	return text[:maxOutput] + fmt.Sprintf("\n[truncated, %d bytes total]", len(text))
+}
@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopilot/internal/providers"
+)
+
+func TestGrepSkipsSymlinks(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(outside, []byte("password=hunter2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "config.txt"), []byte("password=changeme\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link.txt")); err != nil {
+		t.Skipf("cannot create symlinks: %v", err)
+	}
+
+	toolbox := &Toolbox{Root: root}
+	out, err := toolbox.CallTool(context.Background(), providers.ToolCall{Name: "grep", Arguments: `{"pattern":"password"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "config.txt:1: password=changeme") {
+		t.Errorf("grep output %q is missing the workspace match", out)
+	}
+	if strings.Contains(out, "hunter2") {
+		t.Errorf("grep output %q leaks a file outside the workspace", out)
+	}
+}
+
+func TestRunCommandRefusesExecFlags(t *testing.T) {
+	toolbox := &Toolbox{Root: t.TempDir(), AllowedCommands: []string{"go test", "go build"}}
+	tests := []string{
+		"go test -exec=/bin/sh ./...",
+		"go test -exec /bin/sh ./...",
+		"go test --exec=/bin/sh ./...",
+		"go build -toolexec=/tmp/evil ./...",
+		"go build --toolexec /tmp/evil ./...",
+		"go vet ./...",
+	}
+	for _, command := range tests {
+		args, _ := json.Marshal(map[string]string{"command": command})
+		_, err := toolbox.CallTool(context.Background(), providers.ToolCall{Name: "run_command", Arguments: string(args)})
+		if err == nil || !strings.Contains(err.Error(), "command not allowed") {
+			t.Errorf("%q: got %v, want it refused", command, err)
+		}
+	}
+
+	if !toolbox.allowed(strings.Fields("go test -run TestExecutor ./...")) {
+		t.Error("go test with a test name mentioning exec was refused")
+	}
+}
@@ -0,0 +1,86 @@
+package chat
+
+import (
+	"fmt"
+	"time"
+
+	"gopilot/internal/config"
+	"gopilot/internal/providers"
+	"gopilot/internal/usage"
+)
+
+// BudgetError reports a request that was not sent because it would exceed
+// one of the configured budget limits
+type BudgetError struct {
+	// Limit names the setting that would be exceeded, e.g. MAX_COST
+	Limit string
+	// Needed is the estimated requirement and Max the configured limit
+	Needed float64
+	Max    float64
+}
+
+func (e *BudgetError) Error() string {
+	switch e.Limit {
+	case "MAX_INPUT_TOKENS":
+		return fmt.Sprintf("budget exceeded: request needs about %.0f input tokens, %s is %.0f", e.Needed, e.Limit, e.Max)
+	case "MAX_DAILY_COST":
+		return fmt.Sprintf("budget exceeded: request could bring today's spend to $%.4f, %s is $%.4f", e.Needed, e.Limit, e.Max)
+	default:
+		return fmt.Sprintf("budget exceeded: request could bring this run's cost to $%.4f, %s is $%.4f", e.Needed, e.Limit, e.Max)
+	}
+}
+
+// applyBudget caps the request's output at MAX_OUTPUT_TOKENS, then estimates
+// it before it is dispatched and returns a *BudgetError if it could exceed a
+// limit. Costs assume the worst case, a response as long as the output limit
+// allows. MAX_COST covers the whole run, so the cost of the requests already
+// made, such as earlier agent steps, schema retries and summaries, counts
+// towards it.
+func (s *Session) applyBudget(request *providers.Request) error {
+	budget := s.cfg.Budget
+	if budget == (config.BudgetConfig{}) {
+		return nil
+	}
+
+	if budget.MaxOutputTokens > 0 && (request.Sampling.MaxTokens <= 0 || request.Sampling.MaxTokens > budget.MaxOutputTokens) {
+		request.Sampling.MaxTokens = budget.MaxOutputTokens
+	}
+
+	inputTokens := s.tokenizer.PromptTokens(request.History, request.Message)
+	if budget.MaxInputTokens > 0 && inputTokens > budget.MaxInputTokens {
+		return &BudgetError{Limit: "MAX_INPUT_TOKENS", Needed: float64(inputTokens), Max: float64(budget.MaxInputTokens)}
+	}
+
+	if budget.MaxCost <= 0 && budget.MaxDailyCost <= 0 {
+		return nil
+	}
+
+	info, ok := providers.LookupModel(s.providerName, s.model)
+	if !ok {
+		return fmt.Errorf("cannot enforce the cost budget: no pricing for %s model %q, add it under MODELS in the config", s.providerName, s.model)
+	}
+	outputTokens := request.Sampling.MaxTokens
+	if outputTokens <= 0 {
+		outputTokens = info.MaxOutputTokens
+	}
+	cost, _ := providers.Cost(s.providerName, s.model, providers.Usage{
+		PromptTokens:     inputTokens,
+		CompletionTokens: outputTokens,
+	})
+
+	if budget.MaxCost > 0 && *s.spent+cost > budget.MaxCost {
+		return &BudgetError{Limit: "MAX_COST", Needed: *s.spent + cost, Max: budget.MaxCost}
+	}
+
+	if budget.MaxDailyCost > 0 {
+		now := time.Now()
+		spent, err := usage.CostSince(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+		if err != nil {
+			return fmt.Errorf("cannot enforce the daily budget: reading usage ledger: %w", err)
+		}
+		if spent+cost > budget.MaxDailyCost {
+			return &BudgetError{Limit: "MAX_DAILY_COST", Needed: spent + cost, Max: budget.MaxDailyCost}
+		}
+	}
+	return nil
+}
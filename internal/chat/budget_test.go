@@ -0,0 +1,70 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gopilot/internal/config"
+	"gopilot/internal/providers"
+)
+
+func TestMaxCostCoversTheRun(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// A dollar per thousand tokens, so each reply costs about $1.01
+	cfg := &config.Config{
+		Models: map[string]providers.ModelInfo{
+			"fake-model": {ContextWindow: 100000, MaxOutputTokens: 10, InputPrice: 1000, OutputPrice: 1000},
+		},
+		Budget: config.BudgetConfig{MaxCost: 1},
+	}
+	s := newTestSession(t, cfg, "budget", func(providers.Request) (*providers.Response, error) {
+		return &providers.Response{Content: "ok", Usage: providers.Usage{PromptTokens: 1000, CompletionTokens: 10}}, nil
+	})
+
+	if _, err := s.Send(context.Background(), "first", Options{}); err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+
+	// The second request alone fits MAX_COST, but not on top of the first
+	_, err := s.Send(context.Background(), "second", Options{})
+	var budgetErr *BudgetError
+	if !errors.As(err, &budgetErr) || budgetErr.Limit != "MAX_COST" {
+		t.Fatalf("second request: got %v, want a MAX_COST budget error", err)
+	}
+	if budgetErr.Needed <= 1.01 || budgetErr.Needed > 1.1 {
+		t.Errorf("needed = %.4f, want the first reply's cost plus the estimate", budgetErr.Needed)
+	}
+}
+
+func TestSummaryCountsTowardsMaxCost(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	price := providers.ModelInfo{ContextWindow: 100000, MaxOutputTokens: 100, InputPrice: 1000, OutputPrice: 1000}
+	cfg := &config.Config{
+		Models:  map[string]providers.ModelInfo{"fake-model": price, "fake-summarizer": price},
+		Summary: config.SummaryConfig{Threshold: 1, KeepTurns: 1, Model: "fake-summarizer"},
+		Budget:  config.BudgetConfig{MaxCost: 1.2},
+	}
+	// Answers cost about a cent, the summary $1.16
+	s := newTestSession(t, cfg, "budget", func(request providers.Request) (*providers.Response, error) {
+		if len(request.History) > 0 && request.History[0].Content.Text() == summaryPrompt {
+			return &providers.Response{Content: "summary", Usage: providers.Usage{PromptTokens: 1150, CompletionTokens: 10}}, nil
+		}
+		return &providers.Response{Content: "ok", Usage: providers.Usage{PromptTokens: 10, CompletionTokens: 1}}, nil
+	})
+
+	for _, input := range []string{"first", "second"} {
+		if _, err := s.Send(context.Background(), input, Options{}); err != nil {
+			t.Fatalf("%s request: %v", input, err)
+		}
+	}
+
+	// The third request summarizes the first turn, leaving too little for itself
+	_, err := s.Send(context.Background(), "third", Options{})
+	var budgetErr *BudgetError
+	if !errors.As(err, &budgetErr) || budgetErr.Limit != "MAX_COST" {
+		t.Fatalf("third request: got %v, want a MAX_COST budget error", err)
+	}
+}
@@ -0,0 +1,56 @@
+package chat
+
+import (
+	"context"
+	"testing"
+
+	"gopilot/internal/config"
+	"gopilot/internal/providers"
+)
+
+func init() {
+	providers.Register("fake", nil, func(opts providers.Options) (providers.Provider, error) {
+		return &fakeProvider{model: opts.Model}, nil
+	})
+}
+
+// fakeSend answers the requests made to the fake provider
+var fakeSend func(request providers.Request) (*providers.Response, error)
+
+// fakeProvider hands every request to fakeSend, so tests script the model
+type fakeProvider struct {
+	model string
+}
+
+func (p *fakeProvider) Send(ctx context.Context, request providers.Request, sink providers.StreamSink) (*providers.Response, error) {
+	return fakeSend(request)
+}
+
+func (p *fakeProvider) SupportsStreaming() bool { return false }
+
+func (p *fakeProvider) HandleRateLimiting(err error) error { return err }
+
+func (p *fakeProvider) Model() string { return p.model }
+
+// reply answers every request with the given text
+func reply(text string) func(providers.Request) (*providers.Response, error) {
+	return func(providers.Request) (*providers.Response, error) {
+		return &providers.Response{Content: text}, nil
+	}
+}
+
+// newTestSession opens the named session on the fake provider, answering
+// requests with send. Sessions are stored under HOME, which the caller sets.
+func newTestSession(t *testing.T, cfg *config.Config, name string, send func(providers.Request) (*providers.Response, error)) *Session {
+	t.Helper()
+	cfg.Provider = "fake"
+	if cfg.Model == "" {
+		cfg.Model = "fake-model"
+	}
+	fakeSend = send
+	s, err := NewSession(cfg, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
@@ -0,0 +1,208 @@
+package chat
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"gopilot/internal/providers"
+)
+
+// truncatedMarker is appended to context trimmed to fit the context window
+const truncatedMarker = "\n[... %d characters truncated to fit the context window ...]"
+
+// contextLimit returns the tokens a request may use: the override if set,
+// then MAX_CONTEXT_TOKENS, then the model's context window. Zero means the
+// limit is unknown.
+func (s *Session) contextLimit(override int) int {
+	if override > 0 {
+		return override
+	}
+	if s.cfg.MaxContextTokens > 0 {
+		return s.cfg.MaxContextTokens
+	}
+	info, _ := providers.LookupModel(s.providerName, s.model)
+	return info.ContextWindow
+}
+
+// fitContext packs the request's history into the context window, leaving
+// room for the new message, the tools and the response. System messages are
+// pinned and the most recent turn is always kept. The oldest turns are
+// dropped first, whole so tool calls stay with their results, and replaced
+// by a marker; if the pinned messages still do not fit, their largest texts
+// and files are trimmed, then the tool results and files of the most recent
+// turn and the new message. If even that is not enough, an error is
+// returned rather than sending a request the model would reject. The
+// session's own history is not changed.
+func (s *Session) fitContext(request *providers.Request, override int) error {
+	limit := s.contextLimit(override)
+	if limit <= 0 {
+		return nil
+	}
+
+	// Reserve room for the response, but never more than half the window
+	reserve := request.Sampling.MaxTokens
+	if reserve <= 0 {
+		info, _ := providers.LookupModel(s.providerName, s.model)
+		reserve = info.MaxOutputTokens
+	}
+	reserve = min(reserve, limit/2)
+
+	available := limit - reserve - s.tokenizer.MessageTokens(providers.Message{Role: "user", Content: request.Message})
+	for _, tool := range request.Tools {
+		available -= s.tokenizer.Tokens(tool.Name + tool.Description + string(tool.Parameters))
+	}
+
+	history := request.History
+	total := 0
+	for _, msg := range history {
+		total += s.tokenizer.MessageTokens(msg)
+	}
+	if total <= available {
+		return nil
+	}
+
+	// Drop whole turns, each starting at a user message, oldest first
+	lastTurn := len(history)
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "user" {
+			lastTurn = i
+			break
+		}
+	}
+	dropped := make([]bool, len(history))
+	droppedCount := 0
+	for i := 0; i < lastTurn && total > available; {
+		end := i + 1
+		for end < lastTurn && history[end].Role != "user" {
+			end++
+		}
+		for j := i; j < end; j++ {
+			if history[j].Role != "system" {
+				dropped[j] = true
+				droppedCount++
+				total -= s.tokenizer.MessageTokens(history[j])
+			}
+		}
+		i = end
+	}
+
+	packed := make([]providers.Message, 0, len(history)-droppedCount+1)
+	markerAt := -1
+	for i, msg := range history {
+		if !dropped[i] {
+			packed = append(packed, msg)
+		} else if markerAt < 0 {
+			markerAt = len(packed)
+		}
+	}
+
+	var marker providers.Message
+	if markerAt >= 0 {
+		marker = providers.Message{
+			Role:    "system",
+			Content: providers.TextContent(fmt.Sprintf("[%d earlier messages were left out to fit the context window]", droppedCount)),
+		}
+		total += s.tokenizer.MessageTokens(marker)
+	}
+	excess := total - available
+	if excess > 0 {
+		excess = s.trimParts(packed, excess, pinnedPart)
+	}
+	if excess > 0 {
+		// The latest turn alone is over the limit. It is kept whole at the
+		// end of packed, as only earlier turns are dropped.
+		latest := len(history) - lastTurn
+		turn := append([]providers.Message(nil), packed[len(packed)-latest:]...)
+		turn = append(turn, providers.Message{Role: "user", Content: request.Message})
+		excess = s.trimParts(turn, excess, bulkyPart)
+		copy(packed[len(packed)-latest:], turn[:latest])
+		request.Message = turn[latest].Content
+	}
+	if excess > 0 {
+		return fmt.Errorf("the latest message needs about %d more tokens than the %d-token context allows, even with earlier turns dropped and files and tool results trimmed; shorten the message or raise MAX_CONTEXT_TOKENS", excess, limit)
+	}
+
+	// A single marker takes the place of the first dropped message
+	if markerAt >= 0 {
+		packed = append(packed[:markerAt], append([]providers.Message{marker}, packed[markerAt:]...)...)
+	}
+	request.History = packed
+	return nil
+}
+
+// pinnedPart reports whether a part is a text or text file in a system
+// message, which are trimmed before the latest turn is touched
+func pinnedPart(msg providers.Message, part providers.Part) bool {
+	return msg.Role == "system" && (part.Type == providers.PartText || part.IsTextFile())
+}
+
+// bulkyPart reports whether a part is a tool result or a text file, the
+// parts of the latest turn that may be trimmed. The user's own text is not.
+func bulkyPart(msg providers.Message, part providers.Part) bool {
+	return part.Type == providers.PartToolResult || part.IsTextFile()
+}
+
+// trimParts shortens the largest of the parts selected by include until
+// excess tokens have been removed, and returns the tokens still in excess.
+// Messages are copied before they are changed, as their content is shared
+// with the session's history.
+func (s *Session) trimParts(history []providers.Message, excess int, include func(providers.Message, providers.Part) bool) int {
+	type candidate struct {
+		message, part, tokens int
+	}
+	var candidates []candidate
+	for i, msg := range history {
+		for j, part := range msg.Content {
+			if include(msg, part) {
+				candidates = append(candidates, candidate{i, j, s.tokenizer.Tokens(partText(part))})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].tokens > candidates[b].tokens
+	})
+
+	// Allow for the marker added to each trimmed part
+	markerTokens := s.tokenizer.Tokens(fmt.Sprintf(truncatedMarker, 0)) + 2
+	for _, c := range candidates {
+		if excess <= 0 {
+			break
+		}
+		keep := max(c.tokens-excess-markerTokens, 0)
+		if keep >= c.tokens {
+			continue
+		}
+
+		content := append(providers.Content(nil), history[c.message].Content...)
+		content[c.part] = truncatePart(content[c.part], s.tokenizer.Chars(keep))
+		history[c.message].Content = content
+		excess -= c.tokens - s.tokenizer.Tokens(partText(content[c.part]))
+	}
+	return excess
+}
+
+// partText returns the text held by a text part or a text file part
+func partText(part providers.Part) string {
+	if part.Type == providers.PartFile {
+		return string(part.Data)
+	}
+	return part.Text
+}
+
+// truncatePart keeps the first chars characters of a part's text and
+// appends a marker saying how much was cut
+func truncatePart(part providers.Part, chars int) providers.Part {
+	text := partText(part)
+	total := utf8.RuneCountInString(text)
+	if chars >= total {
+		return part
+	}
+	kept := string([]rune(text)[:chars]) + fmt.Sprintf(truncatedMarker, total-chars)
+	if part.Type == providers.PartFile {
+		part.Data = []byte(kept)
+	} else {
+		part.Text = kept
+	}
+	return part
+}
@@ -0,0 +1,83 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+
+	"gopilot/internal/config"
+	"gopilot/internal/providers"
+)
+
+// contextRequest is a request whose history holds an old exchange and a
+// latest turn in which a tool returned output
+func contextRequest(toolOutput string) providers.Request {
+	return providers.Request{
+		History: []providers.Message{
+			{Role: "system", Content: providers.TextContent("be brief")},
+			{Role: "user", Content: providers.TextContent(strings.Repeat("old question ", 20))},
+			{Role: "assistant", Content: providers.TextContent(strings.Repeat("old answer ", 20))},
+			{Role: "user", Content: providers.TextContent("run the tests")},
+			{Role: "assistant", Content: providers.Content{providers.ToolCallPart(providers.ToolCall{ID: "1", Name: "run_command", Arguments: `{}`})}},
+			{Role: "tool", Content: providers.Content{providers.ToolResultPart("1", toolOutput)}},
+		},
+		Sampling: providers.Sampling{MaxTokens: 20},
+	}
+}
+
+func TestFitContextDropsOldTurns(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	s := newTestSession(t, &config.Config{MaxContextTokens: 150}, "context", reply("ok"))
+
+	request := contextRequest("PASS")
+	request.Message = providers.TextContent("and now?")
+	if err := s.fitContext(&request, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var roles []string
+	for _, msg := range request.History {
+		roles = append(roles, msg.Role)
+	}
+	if got, want := strings.Join(roles, " "), "system system user assistant tool"; got != want {
+		t.Fatalf("roles = %q, want %q", got, want)
+	}
+	if got := request.History[1].Content.Text(); !strings.Contains(got, "2 earlier messages") {
+		t.Errorf("marker = %q, want it to count the dropped messages", got)
+	}
+}
+
+func TestFitContextTrimsTheLatestTurn(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	s := newTestSession(t, &config.Config{MaxContextTokens: 150}, "context", reply("ok"))
+
+	output := strings.Repeat("FAIL ", 500)
+	request := contextRequest(output)
+	history := append([]providers.Message(nil), request.History...)
+	if err := s.fitContext(&request, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if tokens := s.tokenizer.PromptTokens(request.History, request.Message); tokens > 150-20 {
+		t.Errorf("prompt is %d tokens, want it to fit in 130", tokens)
+	}
+	result := request.History[len(request.History)-1].Content.Text()
+	if !strings.HasPrefix(result, "FAIL") || !strings.Contains(result, "characters truncated") {
+		t.Errorf("tool result = %q, want it trimmed with a marker", result)
+	}
+	if history[len(history)-1].Content.Text() != output {
+		t.Error("trimming changed the session's history")
+	}
+}
+
+func TestFitContextRejectsAnOversizedMessage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	s := newTestSession(t, &config.Config{MaxContextTokens: 150}, "context", reply("ok"))
+
+	// The user's own text is never trimmed
+	request := contextRequest("PASS")
+	request.Message = providers.TextContent(strings.Repeat("why? ", 200))
+	err := s.fitContext(&request, 0)
+	if err == nil || !strings.Contains(err.Error(), "MAX_CONTEXT_TOKENS") {
+		t.Fatalf("got %v, want an error asking for a shorter message", err)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package chat
+
+// lockFile is a no-op on platforms without advisory locks; writes are still
+// atomic, but concurrent saves are not merged.
+func lockFile(path string, exclusive bool) (func(), error) {
+	return func() {}, nil
+}
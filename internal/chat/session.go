@@ -1,126 +1,442 @@
 package chat
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"mime"
+	"net/http"
 	"path/filepath"
+	"reflect"
+	"time"
 
 	"gopilot/internal/config"
 	"gopilot/internal/providers"
+	"gopilot/internal/schema"
+	"gopilot/internal/usage"
 )
 
 type Options struct {
-	Stream  bool
+	// Sink receives the response as it streams in; nil disables streaming
+	Sink    providers.StreamSink
 	NewChat bool
+	// OneShot keeps the exchange in memory only, so follow-up requests in the
+	// same run (such as tool results) still see it, but nothing is saved
 	OneShot bool
+	// Action names the action the request is made for. It selects the
+	// action's sampling overrides and is recorded in the usage ledger.
+	Action string
+	// Sampling overrides the configured generation parameters, e.g. from flags
+	Sampling providers.Sampling
+	// Tools are the functions offered to the model for this request
+	Tools []providers.Tool
+	// Images are attached to the input, after its text
+	Images []providers.Part
+	// Schema, when set, requests JSON output and validates the response
+	// against it, re-prompting with the errors on a mismatch
+	Schema *schema.Schema
+	// MaxContextTokens overrides the context window the history is packed
+	// into; zero uses MAX_CONTEXT_TOKENS or the model's catalog entry
+	MaxContextTokens int
+}
+
+// Reply is the response to a Send along with what it consumed
+type Reply struct {
+	Content string
+	// ToolCalls are the tools the model asked to run; answer them with
+	// AddToolResult and Send a nil input to continue
+	ToolCalls []providers.ToolCall
+	Usage     providers.Usage
+	// Cost is the estimated cost in USD; Priced is false when the model has
+	// no pricing in the catalog
+	Cost   float64
+	Priced bool
+	// Value is the decoded JSON response when a schema was given
+	Value interface{}
 }
 
 type Message struct {
-	Role    string      `json:"role"`
-	Content interface{} `json:"content"`
+	Role    string            `json:"role"`
+	Content providers.Content `json:"content"`
+	// Archive is set on a summary message, pointing at the messages it replaced
+	Archive *ArchiveRef `json:"archive,omitempty"`
+}
+
+// UnmarshalJSON also reads messages saved before tool calls and results
+// were content parts, when they were fields of the message
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var msg struct {
+		Role       string               `json:"role"`
+		Content    providers.Content    `json:"content"`
+		ToolCalls  []providers.ToolCall `json:"tool_calls"`
+		ToolCallID string               `json:"tool_call_id"`
+		Archive    *ArchiveRef          `json:"archive"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+
+	m.Role, m.Content, m.Archive = msg.Role, msg.Content, msg.Archive
+	if msg.ToolCallID != "" {
+		m.Content = providers.Content{providers.ToolResultPart(msg.ToolCallID, msg.Content.Text())}
+	}
+	for _, call := range msg.ToolCalls {
+		m.Content = append(m.Content, providers.ToolCallPart(call))
+	}
+	return nil
 }
 
 type Session struct {
-	provider    providers.Provider
-	history     []Message
-	historyFile string
+	provider     providers.Provider
+	providerName string
+	model        string
+	tokenizer    providers.Tokenizer
+	cfg          *config.Config
+	name         string
+	tree         tree
+	historyFile  string
+
+	// loaded is the history file as last read or written, to detect saves
+	// by other processes, and loadedHead and loadedMax its head and highest
+	// ID. added lists the messages added since, and parents the messages
+	// that were given a new parent, by ID. moved is set when the head was
+	// moved back and reset when the history was cleared or rewritten.
+	loaded     []byte
+	loadedHead int
+	loadedMax  int
+	added      []int
+	parents    map[int]int
+	moved      bool
+	reset      bool
+	// corruptBackup is where an unreadable history file was moved
+	corruptBackup string
+	// summarizer writes summaries of old turns; nil when they are disabled
+	summarizer *Session
+	// spent is the estimated cost of the requests made so far in this run,
+	// shared with the summarizer so MAX_COST covers every request
+	spent *float64
 }
 
-func NewSession(cfg *config.Config) *Session {
-	provider, err := providers.New(cfg.Provider, cfg.APIKey, cfg.Model)
+// NewSession opens the named conversation, creating it on first use, and
+// connects to the configured provider. An empty name opens the default
+// session.
+func NewSession(cfg *config.Config, name string) (*Session, error) {
+	if name == "" {
+		name = DefaultSessionName
+	}
+	historyFile, err := sessionPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	providers.RegisterModels(cfg.Provider, cfg.Models)
+
+	opts, err := cfg.ProviderOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := providers.New(cfg.Provider, opts)
 	if err != nil {
-		fmt.Printf("Warning: Failed to initialize provider: %v\n", err)
+		return nil, fmt.Errorf("failed to initialize provider: %w", err)
+	}
+
+	// Pricing and context windows are looked up for the model actually used
+	model := opts.Model
+	if namer, ok := provider.(providers.ModelNamer); ok {
+		model = namer.Model()
 	}
 
 	s := &Session{
-		provider:    provider,
-		historyFile: getHistoryFilePath(),
+		provider:     provider,
+		providerName: providers.CanonicalName(cfg.Provider),
+		model:        model,
+		tokenizer:    providers.TokenizerFor(cfg.Provider),
+		cfg:          cfg,
+		name:         name,
+		historyFile:  historyFile,
+		spent:        new(float64),
+	}
+	if s.summarizer, err = s.newSummarizer(opts); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadHistory(); err != nil {
+		var corrupt *CorruptHistoryError
+		if !errors.As(err, &corrupt) {
+			return nil, err
+		}
+		if s.corruptBackup, err = backupHistory(historyFile); err != nil {
+			return nil, err
+		}
 	}
+	return s, nil
+}
+
+// CorruptBackup returns where the session's history file was moved because
+// it could not be read, or "" if it loaded cleanly. The session then starts
+// with an empty history.
+func (s *Session) CorruptBackup() string {
+	return s.corruptBackup
+}
+
+// NewChat clears the history to start a new conversation, replacing the
+// saved one with the next exchange
+func (s *Session) NewChat() {
+	s.tree = tree{}
+	s.added, s.parents = nil, nil
+	s.reset = true
+}
 
-	s.loadHistory()
-	return s
+// addMessage appends a message to the active branch, to be saved with the
+// next exchange
+func (s *Session) addMessage(msg Message) {
+	s.tree.Head = s.tree.add(s.tree.Head, msg)
+	s.added = append(s.added, s.tree.Head)
 }
 
 func (s *Session) AddContext(context string) {
-	s.history = append(s.history, Message{
+	s.addMessage(Message{
 		Role:    "system",
-		Content: context,
+		Content: providers.TextContent(context),
 	})
 }
 
-func (s *Session) Send(input interface{}, opts Options) (string, error) {
-	if opts.NewChat {
-		s.history = nil
+// AddContextFile adds a file as context, typed by its extension or, failing
+// that, its contents
+func (s *Session) AddContextFile(name string, data []byte) {
+	mimeType := mime.TypeByExtension(filepath.Ext(name))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
 	}
+	s.addMessage(Message{
+		Role:    "system",
+		Content: providers.Content{providers.FilePart(name, mimeType, data)},
+	})
+}
+
+// AddToolResult records the output of a tool the model called, to be sent
+// with the next request
+func (s *Session) AddToolResult(callID, content string) {
+	s.addMessage(Message{
+		Role:    "tool",
+		Content: providers.Content{providers.ToolResultPart(callID, content)},
+	})
+}
 
+// Send sends input to the provider with the session's history. A nil input
+// continues the conversation, e.g. after tool results have been added.
+func (s *Session) Send(ctx context.Context, input interface{}, opts Options) (*Reply, error) {
+	if opts.NewChat {
+		s.NewChat()
+	}
+	// Summaries are saved with the history, so a one-shot request only packs it
 	if !opts.OneShot {
-		s.history = append(s.history, Message{
-			Role:    "user",
-			Content: input,
-		})
+		if err := s.maybeSummarize(ctx); err != nil {
+			return nil, err
+		}
 	}
 
-	messages := s.formatHistoryForProvider()
+	content := providers.ContentOf(input)
+	if content != nil && len(opts.Images) > 0 {
+		if err := s.checkVision(); err != nil {
+			return nil, err
+		}
+		content = append(append(providers.Content{}, content...), opts.Images...)
+	}
 
-	response, err := s.provider.Send(messages, input, opts.Stream)
+	var reply *Reply
+	var err error
+	if opts.Schema != nil {
+		reply, err = s.sendStructured(ctx, content, opts)
+	} else {
+		reply, err = s.send(ctx, s.FormatHistoryForProvider(), content, opts, opts.Sink)
+	}
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
+	if content != nil {
+		s.addMessage(Message{
+			Role:    "user",
+			Content: content,
+		})
+	}
+	// The history keeps the response itself, not the JSON it is wrapped in below
+	s.addMessage(Message{
+		Role:    "assistant",
+		Content: reply.historyContent(),
+	})
+
 	// Try to detect if input was JSON and format response accordingly
 	if _, ok := input.(map[string]interface{}); ok {
 		responseObj := map[string]interface{}{
 			"message":  input,
-			"response": response,
+			"response": reply.Content,
+			"usage":    reply.usageSummary(),
+		}
+		if reply.Value != nil {
+			responseObj["response"] = reply.Value
 		}
-		jsonResponse, err := json.MarshalIndent(responseObj, "", "  ")
-		if err != nil {
-			return response, nil
+		if len(reply.ToolCalls) > 0 {
+			responseObj["tool_calls"] = reply.ToolCalls
+		}
+		if jsonResponse, err := json.MarshalIndent(responseObj, "", "  "); err == nil {
+			reply.Content = string(jsonResponse)
 		}
-		response = string(jsonResponse)
 	}
 
+	// As with the usage ledger, the reply is returned even if it could not be saved
 	if !opts.OneShot {
-		s.history = append(s.history, Message{
-			Role:    "assistant",
-			Content: response,
-		})
 		s.saveHistory()
 	}
 
-	return response, nil
+	return reply, nil
 }
 
-func getHistoryFilePath() string {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return ".gopilot_history.json"
+// Undo drops the last exchange, from the last user message on, and saves
+// the session. The exchange stays in the tree as a branch that can still be
+// forked.
+func (s *Session) Undo() error {
+	turn := s.lastTurn()
+	if turn == nil {
+		return fmt.Errorf("session %q has no exchange to undo", s.name)
 	}
-	return filepath.Join(homeDir, ".gopilot_history.json")
+	s.tree.Head = turn.Parent
+	s.moved = true
+	return s.saveHistory()
 }
 
-func (s *Session) loadHistory() {
-	data, err := os.ReadFile(s.historyFile)
-	if err != nil {
-		return
+// Retry sends the last user message again and replaces the answer with the
+// new one. The previous answer is kept in the tree as a branch.
+func (s *Session) Retry(ctx context.Context, opts Options) (*Reply, error) {
+	turn := s.lastTurn()
+	if turn == nil {
+		return nil, fmt.Errorf("session %q has no message to retry", s.name)
+	}
+	s.tree.Head = turn.ID
+	s.moved = true
+	opts.NewChat = false
+	return s.Send(ctx, nil, opts)
+}
+
+// lastTurn returns the last user message of the active branch, or nil
+func (s *Session) lastTurn() *Node {
+	path := s.Path()
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i].Message.Role == "user" {
+			return &path[i]
+		}
 	}
+	return nil
+}
 
-	json.Unmarshal(data, &s.history)
+// checkVision returns an error if the model's catalog entry says it cannot
+// take images. Models missing from the catalog are left to the provider.
+func (s *Session) checkVision() error {
+	info, ok := providers.LookupModel(s.providerName, s.model)
+	if ok && !info.Vision {
+		return fmt.Errorf("model %s does not accept images; choose a vision model (see gopilot models) or set VISION in MODELS", s.model)
+	}
+	return nil
 }
 
-func (s *Session) saveHistory() {
-	data, err := json.Marshal(s.history)
+// send makes a single request with the given history and returns the raw
+// response. The session's history is left untouched.
+func (s *Session) send(ctx context.Context, history []providers.Message, input providers.Content, opts Options, sink providers.StreamSink) (*Reply, error) {
+	request := providers.Request{
+		History:  history,
+		Message:  input,
+		Sampling: s.cfg.SamplingFor(opts.Action).Merge(opts.Sampling),
+		Tools:    opts.Tools,
+	}
+	if opts.Schema != nil {
+		request.ResponseFormat = &providers.ResponseFormat{Schema: opts.Schema.Raw()}
+	}
+	if err := s.fitContext(&request, opts.MaxContextTokens); err != nil {
+		return nil, err
+	}
+	if err := s.applyBudget(&request); err != nil {
+		return nil, err
+	}
+
+	providerSink := sink
+	if !s.provider.SupportsStreaming() {
+		providerSink = nil
+	}
+
+	result, err := s.provider.Send(ctx, request, providerSink)
 	if err != nil {
-		return
+		return nil, err
+	}
+
+	// Providers that cannot stream still render through the sink, in one piece
+	if sink != nil && providerSink == nil {
+		sink(providers.StreamEvent{Type: providers.EventTextDelta, Text: result.Content})
+		sink(providers.StreamEvent{Type: providers.EventDone})
 	}
 
-	os.WriteFile(s.historyFile, data, 0644)
+	reply := &Reply{
+		Content:   result.Content,
+		ToolCalls: result.ToolCalls,
+		Usage:     s.tokenizer.EstimateUsage(result.Usage, request.History, input, result.Content),
+	}
+	reply.Cost, reply.Priced = providers.Cost(s.providerName, s.model, reply.Usage)
+	*s.spent += reply.Cost
+	s.recordUsage(reply, opts.Action)
+	return reply, nil
 }
 
-func (s *Session) formatHistoryForProvider() []providers.Message {
-	messages := make([]providers.Message, len(s.history))
-	for i, msg := range s.history {
+// recordUsage appends the reply's usage to the ledger. The ledger is a
+// convenience, so failing to write it does not fail the request.
+func (s *Session) recordUsage(reply *Reply, action string) {
+	usage.Append(usage.Entry{
+		Time:             time.Now(),
+		Provider:         s.providerName,
+		Model:            s.model,
+		Action:           action,
+		PromptTokens:     reply.Usage.PromptTokens,
+		CompletionTokens: reply.Usage.CompletionTokens,
+		Cost:             reply.Cost,
+		Estimated:        reply.Usage.Estimated,
+	})
+}
+
+// historyContent is the reply as stored in the history: the response, as a
+// json part when it was validated against a schema, and any tool calls
+func (r *Reply) historyContent() providers.Content {
+	var content providers.Content
+	if r.Value != nil {
+		content = providers.ContentOf(r.Value)
+	} else {
+		content = providers.TextContent(r.Content)
+	}
+	for _, call := range r.ToolCalls {
+		content = append(content, providers.ToolCallPart(call))
+	}
+	return content
+}
+
+// usageSummary is the usage as included in JSON output
+func (r *Reply) usageSummary() map[string]interface{} {
+	summary := map[string]interface{}{
+		"prompt_tokens":     r.Usage.PromptTokens,
+		"completion_tokens": r.Usage.CompletionTokens,
+		"total_tokens":      r.Usage.TotalTokens(),
+		"estimated":         r.Usage.Estimated,
+	}
+	if r.Priced {
+		summary["cost"] = r.Cost
+	}
+	return summary
+}
+
+// FormatHistoryForProvider converts the stored history into provider messages
+func (s *Session) FormatHistoryForProvider() []providers.Message {
+	history := s.GetHistory()
+	messages := make([]providers.Message, len(history))
+	for i, msg := range history {
 		messages[i] = providers.Message{
 			Role:    msg.Role,
 			Content: msg.Content,
@@ -129,16 +445,55 @@ func (s *Session) formatHistoryForProvider() []providers.Message {
 	return messages
 }
 
+// Name returns the name of the session
+func (s *Session) Name() string {
+	return s.name
+}
+
+// GetHistory returns the messages of the active branch
 func (s *Session) GetHistory() []Message {
-	return s.history
+	return s.tree.messages()
+}
+
+// Path returns the messages of the active branch with their IDs
+func (s *Session) Path() []Node {
+	return s.tree.path(s.tree.Head)
 }
 
+// Nodes returns every message of the session, in every branch
+func (s *Session) Nodes() []Node {
+	return s.tree.Nodes
+}
+
+// Head returns the ID of the last message of the active branch
+func (s *Session) Head() int {
+	return s.tree.Head
+}
+
+// SetHistory replaces the active branch. Messages that are passed back
+// unchanged keep their place in the tree, so an action adding a system
+// prompt inserts it before the conversation instead of copying it.
 func (s *Session) SetHistory(history []providers.Message) {
-	s.history = make([]Message, len(history))
-	for i, msg := range history {
-		s.history[i] = Message{
-			Role:    msg.Role,
-			Content: msg.Content,
+	path := s.Path()
+	head := s.tree.Head
+	s.tree.Head = 0
+	next := 0
+	for _, msg := range history {
+		if next < len(path) && path[next].Message.Role == msg.Role && reflect.DeepEqual(path[next].Message.Content, msg.Content) {
+			if node := s.tree.node(path[next].ID); node.Parent != s.tree.Head {
+				node.Parent = s.tree.Head
+				if s.parents == nil {
+					s.parents = make(map[int]int)
+				}
+				s.parents[node.ID] = node.Parent
+			}
+			s.tree.Head = path[next].ID
+			next++
+			continue
 		}
+		s.addMessage(Message{Role: msg.Role, Content: msg.Content})
+	}
+	if s.tree.Head != head {
+		s.moved = true
 	}
 }
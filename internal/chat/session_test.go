@@ -0,0 +1,84 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopilot/internal/config"
+	"gopilot/internal/providers"
+)
+
+func TestLegacyMessagesAreRead(t *testing.T) {
+	data := `[
+		{"role": "user", "content": "What changed?"},
+		{"role": "user", "content": {"task": "review", "files": ["main.go"]}},
+		{"role": "assistant", "content": "", "tool_calls": [{"id": "call_1", "name": "read_file", "arguments": "{\"path\":\"main.go\"}"}]},
+		{"role": "tool", "content": "package main", "tool_call_id": "call_1"},
+		{"role": "assistant", "content": [{"type": "text", "text": "Nothing"}]}
+	]`
+	var messages []Message
+	if err := json.Unmarshal([]byte(data), &messages); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := messages[0].Content; len(got) != 1 || got[0].Type != providers.PartText || got[0].Text != "What changed?" {
+		t.Errorf("string content = %+v, want a text part", got)
+	}
+	if got := messages[1].Content; len(got) != 1 || got[0].Type != providers.PartJSON {
+		t.Errorf("object content = %+v, want a json part", got)
+	}
+	calls := messages[2].Content.ToolCalls()
+	if len(calls) != 1 || calls[0].ID != "call_1" || calls[0].Name != "read_file" {
+		t.Errorf("tool calls = %+v, want the legacy tool_calls field", calls)
+	}
+	if got := messages[3].Content; len(got) != 1 || got[0].Type != providers.PartToolResult || got[0].ToolCallID != "call_1" || got[0].Text != "package main" {
+		t.Errorf("tool result = %+v, want a tool_result part", got)
+	}
+	if got := messages[4].Content.Text(); got != "Nothing" {
+		t.Errorf("parts content = %q, want Nothing", got)
+	}
+
+	// Saved again, messages use parts only
+	saved, err := json.Marshal(messages[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"role":"tool","content":[{"type":"tool_result","text":"package main","tool_call_id":"call_1"}]}`; string(saved) != want {
+		t.Errorf("saved %s, want %s", saved, want)
+	}
+}
+
+func TestImagesNeedAVisionModel(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	image := providers.ImagePart("chart.png", "image/png", []byte{0x89, 'P', 'N', 'G'})
+
+	var sent providers.Content
+	send := func(request providers.Request) (*providers.Response, error) {
+		sent = request.Message
+		return &providers.Response{Content: "a chart"}, nil
+	}
+	cfg := &config.Config{Models: map[string]providers.ModelInfo{
+		"fake-model":  {ContextWindow: 8000},
+		"fake-vision": {ContextWindow: 8000, Vision: true},
+	}}
+
+	s := newTestSession(t, cfg, "images", send)
+	_, err := s.Send(context.Background(), "What does this show?", Options{Images: []providers.Part{image}})
+	if err == nil || !strings.Contains(err.Error(), "does not accept images") {
+		t.Fatalf("got %v, want an error before sending", err)
+	}
+	if sent != nil {
+		t.Error("the request was sent to a model without vision")
+	}
+
+	cfg.Model = "fake-vision"
+	s = newTestSession(t, cfg, "images", send)
+	if _, err := s.Send(context.Background(), "What does this show?", Options{Images: []providers.Part{image}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 || sent[0].Text != "What does this show?" || sent[1].Type != providers.PartImage {
+		t.Errorf("sent %+v, want the prompt followed by the image", sent)
+	}
+}
@@ -0,0 +1,421 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultSessionName is the conversation used when no session is named
+const DefaultSessionName = "default"
+
+// sessionNamePattern keeps names usable as file names on every platform
+var sessionNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// CorruptHistoryError reports a history file that is not valid JSON
+type CorruptHistoryError struct {
+	Path string
+	Err  error
+}
+
+func (e *CorruptHistoryError) Error() string {
+	return fmt.Sprintf("session history %s is corrupt: %v", e.Path, e.Err)
+}
+
+func (e *CorruptHistoryError) Unwrap() error {
+	return e.Err
+}
+
+// SessionInfo describes a stored conversation
+type SessionInfo struct {
+	Name     string
+	Messages int
+	Updated  time.Time
+}
+
+// SessionsDir returns the directory holding one history file per session
+func SessionsDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ".gopilot_sessions"
+	}
+	return filepath.Join(homeDir, ".gopilot_sessions")
+}
+
+// legacyHistoryPath is the single history file used before named sessions
+func legacyHistoryPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ".gopilot_history.json"
+	}
+	return filepath.Join(homeDir, ".gopilot_history.json")
+}
+
+// ValidateSessionName returns an error if name cannot be used for a session
+func ValidateSessionName(name string) error {
+	if !sessionNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid session name %q: use letters, digits, '.', '_' and '-', starting with a letter or digit", name)
+	}
+	return nil
+}
+
+// sessionPath returns the history file of a session. The default session
+// takes over the legacy history file the first time it is used.
+func sessionPath(name string) (string, error) {
+	if err := ValidateSessionName(name); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(SessionsDir(), 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(SessionsDir(), name+".json")
+	if name == DefaultSessionName {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			os.Rename(legacyHistoryPath(), path)
+		}
+	}
+	return path, nil
+}
+
+// LoadSession opens a stored conversation for reading, without connecting
+// to a provider. It returns an error if the session does not exist.
+func LoadSession(name string) (*Session, error) {
+	path, err := sessionPath(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("session %q not found", name)
+	}
+
+	s := &Session{name: name, historyFile: path}
+	if err := s.loadHistory(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ListSessions returns the stored conversations sorted by name
+func ListSessions() ([]SessionInfo, error) {
+	// Resolving the default session migrates the legacy history file
+	if _, err := sessionPath(DefaultSessionName); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(SessionsDir())
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []SessionInfo
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if entry.IsDir() || name == entry.Name() || ValidateSessionName(name) != nil {
+			continue
+		}
+		fileInfo, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		info := SessionInfo{Name: name, Updated: fileInfo.ModTime()}
+		if data, err := os.ReadFile(filepath.Join(SessionsDir(), entry.Name())); err == nil {
+			if t, err := decodeTree(data); err == nil {
+				info.Messages = len(t.path(t.Head))
+			}
+		}
+		sessions = append(sessions, info)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Name < sessions[j].Name
+	})
+	return sessions, nil
+}
+
+// RemoveSession deletes a stored conversation, its archive and its lock file
+func RemoveSession(name string) error {
+	path, err := sessionPath(name)
+	if err != nil {
+		return err
+	}
+	// Deferred first, so the lock file is removed once it is released
+	defer os.Remove(path + ".lock")
+	unlock, err := lockFile(path+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.Remove(path); os.IsNotExist(err) {
+		return fmt.Errorf("session %q not found", name)
+	} else if err != nil {
+		return err
+	}
+	return os.RemoveAll(archiveDir(path))
+}
+
+// RenameSession renames a stored conversation, refusing to overwrite another.
+// Both names are locked, so a session saved under the new name meanwhile is
+// not replaced.
+func RenameSession(from, to string) error {
+	fromPath, err := sessionPath(from)
+	if err != nil {
+		return err
+	}
+	toPath, err := sessionPath(to)
+	if err != nil {
+		return err
+	}
+	if fromPath == toPath {
+		return fmt.Errorf("session %q already exists", to)
+	}
+
+	// Locks are taken in a fixed order, so opposite renames cannot deadlock.
+	// The old name's lock file is removed once it is released.
+	defer os.Remove(fromPath + ".lock")
+	first, second := fromPath, toPath
+	if second < first {
+		first, second = second, first
+	}
+	unlockFirst, err := lockFile(first+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer unlockFirst()
+	unlockSecond, err := lockFile(second+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer unlockSecond()
+
+	if _, err := os.Stat(fromPath); os.IsNotExist(err) {
+		return fmt.Errorf("session %q not found", from)
+	}
+	if _, err := os.Stat(toPath); err == nil {
+		return fmt.Errorf("session %q already exists", to)
+	}
+	// Archive references are relative, so the archive moves with the session
+	if _, err := os.Stat(archiveDir(fromPath)); err == nil {
+		if err := os.Rename(archiveDir(fromPath), archiveDir(toPath)); err != nil {
+			return err
+		}
+	}
+	return os.Rename(fromPath, toPath)
+}
+
+// ForkSession copies a stored conversation, up to and including the message
+// with the given ID, into a new session. An ID of 0 copies the whole active
+// branch. Archives of the summaries it contains are copied along.
+func ForkSession(from, to string, at int) error {
+	source, err := LoadSession(from)
+	if err != nil {
+		return err
+	}
+	if at == 0 {
+		at = source.tree.Head
+	}
+	path := source.tree.path(at)
+	if len(path) == 0 {
+		return fmt.Errorf("session %q has no message %d", from, at)
+	}
+
+	toPath, err := sessionPath(to)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockFile(toPath+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(toPath); err == nil {
+		return fmt.Errorf("session %q already exists", to)
+	}
+
+	var fork tree
+	for _, node := range path {
+		if ref := node.Message.Archive; ref != nil {
+			if err := copyArchive(source.ArchivePath(ref), filepath.Join(archiveDir(toPath), ref.File)); err != nil {
+				return err
+			}
+		}
+		fork.Head = fork.add(fork.Head, node.Message)
+	}
+
+	data, err := json.Marshal(fork)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(toPath, data)
+}
+
+// copyArchive copies an archive file into another session's archive
+// directory. A missing archive is not copied, as the summary still stands.
+func copyArchive(from, to string) error {
+	data, err := os.ReadFile(from)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(to, data)
+}
+
+// loadHistory reads the history file under a shared lock. A missing file is
+// an empty history, and one that cannot be parsed a *CorruptHistoryError.
+func (s *Session) loadHistory() error {
+	unlock, err := lockFile(s.historyFile+".lock", false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(s.historyFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	t, err := decodeTree(data)
+	if err != nil {
+		return &CorruptHistoryError{Path: s.historyFile, Err: err}
+	}
+	s.tree = t
+	s.setLoaded(data)
+	return nil
+}
+
+// setLoaded records the session file as last read or written
+func (s *Session) setLoaded(data []byte) {
+	s.loaded, s.loadedHead, s.loadedMax = data, s.tree.Head, s.tree.maxID()
+	s.added, s.parents, s.moved, s.reset = nil, nil, false, false
+}
+
+// saveHistory writes the session under an exclusive lock. If another process
+// saved it since it was loaded, the messages added here are merged into its
+// version instead of overwriting it, unless the history was cleared with a
+// new chat or rewritten by a summary.
+func (s *Session) saveHistory() error {
+	unlock, err := lockFile(s.historyFile+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if current, err := os.ReadFile(s.historyFile); err == nil && !s.reset && !bytes.Equal(current, s.loaded) {
+		if saved, err := decodeTree(current); err == nil {
+			s.tree = s.merge(saved)
+		}
+	}
+
+	data, err := json.Marshal(s.tree)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.historyFile, data); err != nil {
+		return err
+	}
+	s.setLoaded(data)
+	return nil
+}
+
+// merge adds the messages added here to the tree saved by another process.
+// Messages that continued the conversation continue it after the other
+// process's messages; the head moves to the last of them, or to where it
+// was moved back to here. Messages given a new parent here, such as by an
+// action inserting a system prompt, are moved there too.
+func (s *Session) merge(saved tree) tree {
+	ids := make(map[int]int, len(s.added))
+	for _, id := range s.added {
+		node := s.tree.node(id)
+		if node == nil {
+			continue
+		}
+		parent, ok := ids[node.Parent]
+		if !ok {
+			parent = node.Parent
+			if parent == s.loadedHead || parent > s.loadedMax || (parent != 0 && saved.node(parent) == nil) {
+				parent = saved.Head
+			}
+		}
+		ids[id] = saved.add(parent, node.Message)
+	}
+	for id, parent := range s.parents {
+		node := saved.node(id)
+		if node == nil || id > s.loadedMax {
+			continue
+		}
+		if added, ok := ids[parent]; ok {
+			parent = added
+		}
+		node.Parent = parent
+	}
+
+	if head, ok := ids[s.tree.Head]; ok {
+		saved.Head = head
+	} else if s.moved && (s.tree.Head == 0 || saved.node(s.tree.Head) != nil) {
+		saved.Head = s.tree.Head
+	}
+	return saved
+}
+
+// writeFileAtomic replaces path with data by writing a temporary file in the
+// same directory and renaming it, so readers never see a partial file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// backupHistory moves a corrupt history file aside, keeping it for recovery,
+// and returns its new path. Names carry nanoseconds, and a counter if that
+// is not enough, so an earlier backup is never overwritten.
+func backupHistory(path string) (string, error) {
+	unlock, err := lockFile(path+".lock", true)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	stamp := time.Now().Format("20060102-150405.000000000")
+	backup := path + ".corrupt-" + stamp
+	for n := 2; ; n++ {
+		if _, err := os.Lstat(backup); os.IsNotExist(err) {
+			break
+		}
+		backup = fmt.Sprintf("%s.corrupt-%s-%d", path, stamp, n)
+	}
+	if err := os.Rename(path, backup); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
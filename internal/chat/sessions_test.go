@@ -0,0 +1,273 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gopilot/internal/config"
+	"gopilot/internal/providers"
+)
+
+// checkExchanges verifies that the session's history holds every question
+// once, each followed by its answer
+func checkExchanges(t *testing.T, name string, questions []string) {
+	t.Helper()
+	s, err := LoadSession(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := s.GetHistory()
+	if len(history) != 2*len(questions) {
+		t.Fatalf("history has %d messages, want %d", len(history), 2*len(questions))
+	}
+
+	seen := make(map[string]int)
+	for i := 0; i < len(history); i += 2 {
+		question, answer := history[i].Content.Text(), history[i+1].Content.Text()
+		if history[i].Role != "user" || answer != "answer to "+question {
+			t.Errorf("messages %d and %d are %s %q and %s %q, want a question and its answer", i, i+1, history[i].Role, question, history[i+1].Role, answer)
+		}
+		seen[question]++
+	}
+	for _, question := range questions {
+		if seen[question] != 1 {
+			t.Errorf("%q is in the history %d times, want once", question, seen[question])
+		}
+	}
+}
+
+// echoAnswer answers every question with "answer to" and the question
+func echoAnswer(request providers.Request) (*providers.Response, error) {
+	return &providers.Response{Content: "answer to " + request.Message.Text()}, nil
+}
+
+func TestConcurrentSends(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// Every session loads the history before any of them saves, so each
+	// save after the first merges into another's
+	const n = 10
+	sessions := make([]*Session, n)
+	questions := make([]string, n)
+	for i := range sessions {
+		sessions[i] = newTestSession(t, &config.Config{}, "shared", echoAnswer)
+		questions[i] = fmt.Sprintf("question %d", i)
+	}
+
+	var wg sync.WaitGroup
+	for i, s := range sessions {
+		wg.Add(1)
+		go func(s *Session, question string) {
+			defer wg.Done()
+			if _, err := s.Send(context.Background(), question, Options{}); err != nil {
+				t.Error(err)
+			}
+		}(s, questions[i])
+	}
+	wg.Wait()
+
+	checkExchanges(t, "shared", questions)
+}
+
+// sessionProcessEnv holds the question a helper process sends
+const sessionProcessEnv = "GOPILOT_TEST_SESSION_QUESTION"
+
+// TestSessionProcess is not a test: it is run as a subprocess by
+// TestConcurrentProcesses to send one question to the shared session
+func TestSessionProcess(t *testing.T) {
+	question := os.Getenv(sessionProcessEnv)
+	if question == "" {
+		t.Skip("only run as a subprocess")
+	}
+	s := newTestSession(t, &config.Config{}, "shared", echoAnswer)
+	if _, err := s.Send(context.Background(), question, Options{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentProcesses(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	const n = 8
+	questions := make([]string, n)
+	commands := make([]*exec.Cmd, n)
+	for i := range commands {
+		questions[i] = fmt.Sprintf("question %d", i)
+		commands[i] = exec.Command(os.Args[0], "-test.run=^TestSessionProcess$")
+		commands[i].Env = append(os.Environ(), sessionProcessEnv+"="+questions[i])
+		if err := commands[i].Start(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, cmd := range commands {
+		if err := cmd.Wait(); err != nil {
+			t.Errorf("process sending %q: %v", questions[i], err)
+		}
+	}
+
+	checkExchanges(t, "shared", questions)
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "session.json")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(path, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "new" {
+		t.Errorf("file holds %q (%v), want new", data, err)
+	}
+
+	// The temporary file is renamed into place, so nothing else is left
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d files, want only the session", len(entries))
+	}
+}
+
+func TestCorruptHistoryIsBackedUp(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path, err := sessionPath("broken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"head": 1, "nodes": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestSession(t, &config.Config{}, "broken", echoAnswer)
+	backup := s.CorruptBackup()
+	if backup == "" {
+		t.Fatal("the corrupt history was not reported")
+	}
+	if data, err := os.ReadFile(backup); err != nil || string(data) != `{"head": 1, "nodes": [` {
+		t.Errorf("backup holds %q (%v), want the corrupt history", data, err)
+	}
+	if len(s.GetHistory()) != 0 {
+		t.Errorf("history has %d messages, want a new conversation", len(s.GetHistory()))
+	}
+
+	if _, err := s.Send(context.Background(), "hello", Options{}); err != nil {
+		t.Fatal(err)
+	}
+	checkExchanges(t, "broken", []string{"hello"})
+}
+
+func TestCorruptBackupsDoNotCollide(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "broken.json")
+
+	var backups []string
+	for _, content := range []string{"first", "second", "third"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		backup, err := backupHistory(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backups = append(backups, backup)
+	}
+
+	for i, content := range []string{"first", "second", "third"} {
+		if data, err := os.ReadFile(backups[i]); err != nil || string(data) != content {
+			t.Errorf("backup %s holds %q (%v), want %q", backups[i], data, err, content)
+		}
+	}
+}
+
+func TestListRenameAndRemoveSessions(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	for _, name := range []string{"beta", "alpha"} {
+		s := newTestSession(t, &config.Config{}, name, echoAnswer)
+		if _, err := s.Send(context.Background(), "hello "+name, Options{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions, err := ListSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].Name != "alpha" || sessions[1].Name != "beta" || sessions[0].Messages != 2 {
+		t.Fatalf("sessions = %+v, want alpha and beta with 2 messages each", sessions)
+	}
+
+	if err := RenameSession("alpha", "beta"); err == nil {
+		t.Error("renaming over an existing session succeeded")
+	}
+	if err := RenameSession("missing", "gamma"); err == nil {
+		t.Error("renaming a missing session succeeded")
+	}
+	if err := RenameSession("alpha", "gamma"); err != nil {
+		t.Fatal(err)
+	}
+	checkExchanges(t, "gamma", []string{"hello alpha"})
+
+	if err := RemoveSession("gamma"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveSession("gamma"); err == nil {
+		t.Error("removing a missing session succeeded")
+	}
+
+	// Only beta and its lock file are left
+	entries, err := os.ReadDir(SessionsDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if got := fmt.Sprint(names); got != "[beta.json beta.json.lock]" {
+		t.Errorf("sessions directory holds %s, want only beta", got)
+	}
+}
+
+func TestRenameWaitsForTheDestination(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	s := newTestSession(t, &config.Config{}, "old", echoAnswer)
+	if _, err := s.Send(context.Background(), "hello", Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process holds the new name while it saves a session there
+	toPath, err := sessionPath("new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := lockFile(toPath+".lock", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- RenameSession("old", "new")
+	}()
+	// Give the rename time to reach the lock
+	time.Sleep(50 * time.Millisecond)
+	if err := os.WriteFile(toPath, []byte(`{"head": 0, "nodes": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+
+	if err := <-done; err == nil {
+		t.Fatal("rename replaced the session saved under the new name")
+	}
+	checkExchanges(t, "old", []string{"hello"})
+}
@@ -0,0 +1,97 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gopilot/internal/providers"
+	"gopilot/internal/schema"
+)
+
+// DefaultSchemaRetries is how many times a response that does not match the
+// schema is sent back for repair before giving up
+const DefaultSchemaRetries = 2
+
+// SchemaError reports a response that still did not match the schema after
+// every repair attempt
+type SchemaError struct {
+	// Errors are the validation errors of the last response
+	Errors   []string
+	Attempts int
+	// Content is the last response received
+	Content string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("response does not match the schema after %d attempts:\n  %s", e.Attempts, strings.Join(e.Errors, "\n  "))
+}
+
+// sendStructured sends input asking for JSON that matches opts.Schema. An
+// invalid response is sent back with its validation errors, up to
+// SCHEMA_RETRIES times. The repair turns are not kept in the history, and
+// since each attempt must be checked before it is shown, the sink only
+// receives the final JSON.
+func (s *Session) sendStructured(ctx context.Context, input providers.Content, opts Options) (*Reply, error) {
+	retries := DefaultSchemaRetries
+	if s.cfg.SchemaRetries != nil {
+		retries = *s.cfg.SchemaRetries
+	}
+
+	history := append(s.FormatHistoryForProvider(), providers.Message{
+		Role:    "system",
+		Content: providers.TextContent("Respond only with a JSON value that conforms to this JSON Schema, without any other text:\n" + strings.TrimSpace(string(opts.Schema.Raw()))),
+	})
+	message := input
+
+	total := &Reply{Priced: true}
+	for attempt := 1; ; attempt++ {
+		reply, err := s.send(ctx, history, message, opts, nil)
+		if err != nil {
+			return nil, err
+		}
+		total.Usage.PromptTokens += reply.Usage.PromptTokens
+		total.Usage.CompletionTokens += reply.Usage.CompletionTokens
+		total.Usage.Estimated = total.Usage.Estimated || reply.Usage.Estimated
+		total.Cost += reply.Cost
+		total.Priced = total.Priced && reply.Priced
+
+		// A tool call is not the final answer, so there is nothing to validate yet
+		if len(reply.ToolCalls) > 0 {
+			total.Content = reply.Content
+			total.ToolCalls = reply.ToolCalls
+			return total, nil
+		}
+
+		text, value, problems := validateResponse(opts.Schema, reply.Content)
+		if len(problems) == 0 {
+			total.Content = text
+			total.Value = value
+			if opts.Sink != nil {
+				opts.Sink(providers.StreamEvent{Type: providers.EventTextDelta, Text: text})
+				opts.Sink(providers.StreamEvent{Type: providers.EventDone})
+			}
+			return total, nil
+		}
+		if attempt > retries {
+			return nil, &SchemaError{Errors: problems, Attempts: attempt, Content: reply.Content}
+		}
+
+		if message != nil {
+			history = append(history, providers.Message{Role: "user", Content: message})
+		}
+		history = append(history, providers.Message{Role: "assistant", Content: providers.TextContent(reply.Content)})
+		message = providers.TextContent("Your response does not match the JSON Schema:\n- " + strings.Join(problems, "\n- ") +
+			"\nRespond again with only the corrected JSON.")
+	}
+}
+
+// validateResponse extracts the JSON from a response and checks it against
+// the schema, returning the JSON text, its decoded value and any errors
+func validateResponse(s *schema.Schema, response string) (string, interface{}, []string) {
+	text, value, err := schema.ExtractJSON(response)
+	if err != nil {
+		return "", nil, []string{err.Error()}
+	}
+	return text, value, s.Validate(value)
+}
@@ -0,0 +1,82 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"gopilot/internal/config"
+	"gopilot/internal/providers"
+	"gopilot/internal/schema"
+)
+
+func mustSchema(t *testing.T, data string) *schema.Schema {
+	t.Helper()
+	s, err := schema.Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSendStructuredRepairsInvalidJSON(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	var requests []providers.Request
+	answers := []string{`{"severity":"urgent"}`, "```json\n{\"severity\":\"high\"}\n```"}
+	s := newTestSession(t, &config.Config{}, "structured", func(request providers.Request) (*providers.Response, error) {
+		requests = append(requests, request)
+		return &providers.Response{Content: answers[len(requests)-1]}, nil
+	})
+
+	opts := Options{Schema: mustSchema(t, `{"type":"object","required":["severity"],"properties":{"severity":{"enum":["low","high"]}}}`)}
+	reply, err := s.Send(context.Background(), "Rate this bug", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Content != `{"severity":"high"}` {
+		t.Errorf("content = %q, want the repaired JSON without its fence", reply.Content)
+	}
+	if value, ok := reply.Value.(map[string]interface{}); !ok || value["severity"] != "high" {
+		t.Errorf("value = %v, want the decoded JSON", reply.Value)
+	}
+
+	// The repair request replays the invalid answer followed by its errors
+	if len(requests) != 2 {
+		t.Fatalf("%d requests, want 2", len(requests))
+	}
+	repair := requests[1]
+	if last := repair.History[len(repair.History)-1]; last.Role != "assistant" || last.Content.Text() != answers[0] {
+		t.Errorf("repair history ends with %s %q, want the invalid answer", last.Role, last.Content.Text())
+	}
+	if text := repair.Message.Text(); !strings.Contains(text, `$.severity: must be one of ["low","high"]`) {
+		t.Errorf("repair message %q does not list the validation error", text)
+	}
+
+	// Only the question and the accepted answer are kept
+	history := s.GetHistory()
+	if len(history) != 2 || history[0].Content.Text() != "Rate this bug" || history[1].Content.Text() != `{"severity":"high"}` {
+		t.Errorf("history = %+v, want the question and the accepted answer", history)
+	}
+}
+
+func TestSendStructuredWithoutRetries(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	requests := 0
+	retries := 0
+	s := newTestSession(t, &config.Config{SchemaRetries: &retries}, "structured", func(providers.Request) (*providers.Response, error) {
+		requests++
+		return &providers.Response{Content: "not JSON"}, nil
+	})
+
+	_, err := s.Send(context.Background(), "Rate this bug", Options{Schema: mustSchema(t, `{"type":"object"}`)})
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) || schemaErr.Attempts != 1 {
+		t.Fatalf("got %v, want a schema error after one attempt", err)
+	}
+	if requests != 1 {
+		t.Errorf("%d requests with SCHEMA_RETRIES 0, want 1", requests)
+	}
+}
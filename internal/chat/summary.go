@@ -0,0 +1,234 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopilot/internal/providers"
+)
+
+const (
+	// DefaultSummaryKeepTurns is how many recent exchanges are kept word for
+	// word when the rest of the history is summarized
+	DefaultSummaryKeepTurns = 4
+	// DefaultSummaryMaxTokens caps the length of a summary
+	DefaultSummaryMaxTokens = 1024
+)
+
+// summaryPrompt instructs the model writing a summary
+const summaryPrompt = `You condense the earlier part of a conversation between a user and an assistant so it can continue without the original messages.
+Write a concise summary in plain prose. Keep every fact, decision, name, file path, identifier, number and piece of code the rest of the conversation may depend on, and any question that is still open.
+Leave out greetings and repetition. Do not add anything that was not said.`
+
+// summaryHeading starts the text of a summary message
+const summaryHeading = "Summary of the earlier conversation:\n"
+
+// ArchiveRef points a summary message at the raw messages it replaced
+type ArchiveRef struct {
+	// File is the archive file, relative to the session's archive directory
+	File string `json:"file"`
+	// Messages is the number of messages archived in the file
+	Messages int `json:"messages"`
+}
+
+// newSummarizer returns the session that writes summaries: the session
+// itself, or one using SUMMARY MODEL. It is nil when summaries are disabled.
+func (s *Session) newSummarizer(opts providers.Options) (*Session, error) {
+	summary := s.cfg.Summary
+	if summary.Threshold <= 0 {
+		return nil, nil
+	}
+	if summary.Model == "" || summary.Model == s.model {
+		return s, nil
+	}
+
+	opts.Model = summary.Model
+	provider, err := providers.New(s.cfg.Provider, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize summary model: %w", err)
+	}
+	return &Session{
+		provider:     provider,
+		providerName: s.providerName,
+		model:        summary.Model,
+		tokenizer:    s.tokenizer,
+		cfg:          s.cfg,
+		name:         s.name,
+		spent:        s.spent,
+	}, nil
+}
+
+// maybeSummarize replaces the older turns of the history with a summary
+// once the history grows past SUMMARY THRESHOLD tokens. The most recent
+// turns and the pinned system messages, such as context files, are kept as
+// they are; the replaced messages are archived next to the session.
+func (s *Session) maybeSummarize(ctx context.Context) error {
+	if s.summarizer == nil {
+		return nil
+	}
+
+	total := 0
+	for _, msg := range s.FormatHistoryForProvider() {
+		total += s.tokenizer.MessageTokens(msg)
+	}
+	if total <= s.cfg.Summary.Threshold {
+		return nil
+	}
+
+	keepTurns := s.cfg.Summary.KeepTurns
+	if keepTurns <= 0 {
+		keepTurns = DefaultSummaryKeepTurns
+	}
+
+	// Keep everything from the start of the keepTurns-th most recent turn
+	path := s.Path()
+	cut, turns := -1, 0
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i].Message.Role == "user" {
+			if turns++; turns == keepTurns+1 {
+				break
+			}
+			cut = i
+		}
+	}
+	if turns <= keepTurns {
+		return nil
+	}
+
+	var pinned, archived []Message
+	replaced := make([]int, cut)
+	for i, node := range path[:cut] {
+		if msg := node.Message; msg.Role == "system" && msg.Archive == nil {
+			pinned = append(pinned, msg)
+		} else {
+			archived = append(archived, msg)
+		}
+		replaced[i] = node.ID
+	}
+	if len(archived) < 2 {
+		return nil
+	}
+
+	summary, err := s.summarize(ctx, archived)
+	if err != nil {
+		return fmt.Errorf("summarizing history: %w", err)
+	}
+	ref, err := s.archive(archived)
+	if err != nil {
+		return fmt.Errorf("archiving history: %w", err)
+	}
+
+	// The kept turns, with any branches off them, move under a new start of
+	// the conversation, and the replaced messages are removed unless another
+	// branch still follows them
+	parent := 0
+	for _, msg := range pinned {
+		parent = s.tree.add(parent, msg)
+	}
+	parent = s.tree.add(parent, Message{
+		Role:    "system",
+		Content: providers.TextContent(summaryHeading + summary),
+		Archive: ref,
+	})
+	s.tree.node(path[cut].ID).Parent = parent
+	s.tree.prune(replaced)
+
+	// The tree was rewritten, so it is saved whole rather than merged
+	s.added, s.reset = nil, true
+	return nil
+}
+
+// summarize asks the summary model to condense the messages. Sampling is
+// pinned to temperature and seed 0 so the same history gives the same
+// summary wherever the provider allows it.
+func (s *Session) summarize(ctx context.Context, messages []Message) (string, error) {
+	zero := 0
+	temperature := 0.0
+	maxTokens := s.cfg.Summary.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultSummaryMaxTokens
+	}
+	opts := Options{
+		Action: "summary",
+		Sampling: providers.Sampling{
+			Temperature: &temperature,
+			Seed:        &zero,
+			MaxTokens:   maxTokens,
+		},
+	}
+
+	history := []providers.Message{{Role: "system", Content: providers.TextContent(summaryPrompt)}}
+	reply, err := s.summarizer.send(ctx, history, providers.TextContent(transcript(messages)), opts, nil)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(reply.Content)
+	if text == "" {
+		return "", fmt.Errorf("the model returned an empty summary")
+	}
+	return text, nil
+}
+
+// transcript renders messages as "role: text" blocks for the summary model,
+// describing the parts that have no text form
+func transcript(messages []Message) string {
+	var blocks []string
+	for _, msg := range messages {
+		var texts []string
+		for _, part := range msg.Content {
+			switch part.Type {
+			case providers.PartImage:
+				texts = append(texts, fmt.Sprintf("[image %s]", part.Name))
+			case providers.PartFile:
+				if !part.IsTextFile() {
+					texts = append(texts, fmt.Sprintf("[file %s]", part.Name))
+				} else {
+					texts = append(texts, providers.Content{part}.Text())
+				}
+			case providers.PartToolCall:
+				if part.ToolCall != nil {
+					texts = append(texts, fmt.Sprintf("[called %s with %s]", part.ToolCall.Name, part.ToolCall.Arguments))
+				}
+			default:
+				texts = append(texts, providers.Content{part}.Text())
+			}
+		}
+		blocks = append(blocks, msg.Role+": "+strings.Join(texts, "\n"))
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// archive writes the messages to a new file in the session's archive
+// directory and returns a reference to it
+func (s *Session) archive(messages []Message) (*ArchiveRef, error) {
+	dir := archiveDir(s.historyFile)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(messages)
+	if err != nil {
+		return nil, err
+	}
+
+	file := time.Now().UTC().Format("20060102-150405.000000000") + ".json"
+	if err := writeFileAtomic(filepath.Join(dir, file), data); err != nil {
+		return nil, err
+	}
+	return &ArchiveRef{File: file, Messages: len(messages)}, nil
+}
+
+// ArchivePath returns the path of the archive a summary message points to
+func (s *Session) ArchivePath(ref *ArchiveRef) string {
+	return filepath.Join(archiveDir(s.historyFile), ref.File)
+}
+
+// archiveDir returns the directory holding the archived messages of the
+// session stored in historyFile
+func archiveDir(historyFile string) string {
+	return strings.TrimSuffix(historyFile, ".json") + ".archive"
+}
@@ -0,0 +1,80 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"gopilot/internal/config"
+	"gopilot/internal/providers"
+)
+
+func TestSummaryReplacesOldTurns(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	var transcripts []string
+	cfg := &config.Config{Summary: config.SummaryConfig{Threshold: 1, KeepTurns: 1}}
+	s := newTestSession(t, cfg, "summary", func(request providers.Request) (*providers.Response, error) {
+		if len(request.History) > 0 && request.History[0].Content.Text() == summaryPrompt {
+			transcripts = append(transcripts, request.Message.Text())
+			return &providers.Response{Content: "The user asked question 1."}, nil
+		}
+		return echoAnswer(request)
+	})
+	s.AddContext("project notes")
+
+	for _, question := range []string{"question 1", "question 2", "question 3"} {
+		if _, err := s.Send(context.Background(), question, Options{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The third request summarized the first turn; the second has only one
+	// turn before the kept one, which is too little to summarize
+	if len(transcripts) != 1 || transcripts[0] != "user: question 1\n\nassistant: answer to question 1" {
+		t.Fatalf("summary transcripts = %q, want one of the first turn", transcripts)
+	}
+
+	saved, err := LoadSession("summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := saved.GetHistory()
+	var texts []string
+	for _, msg := range history {
+		texts = append(texts, msg.Role+": "+msg.Content.Text())
+	}
+	want := []string{
+		"system: project notes",
+		"system: " + summaryHeading + "The user asked question 1.",
+		"user: question 2",
+		"assistant: answer to question 2",
+		"user: question 3",
+		"assistant: answer to question 3",
+	}
+	if strings.Join(texts, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("history =\n%s\nwant\n%s", strings.Join(texts, "\n"), strings.Join(want, "\n"))
+	}
+	if len(saved.Nodes()) != len(want) {
+		t.Errorf("tree has %d nodes, want the replaced messages removed", len(saved.Nodes()))
+	}
+
+	// The summary points at an archive of the messages it replaced
+	ref := history[1].Archive
+	if ref == nil || ref.Messages != 2 {
+		t.Fatalf("summary archive = %+v, want 2 messages", ref)
+	}
+	data, err := os.ReadFile(saved.ArchivePath(ref))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archived []Message
+	if err := json.Unmarshal(data, &archived); err != nil {
+		t.Fatal(err)
+	}
+	if len(archived) != 2 || archived[0].Content.Text() != "question 1" || archived[1].Content.Text() != "answer to question 1" {
+		t.Errorf("archive holds %+v, want the first turn", archived)
+	}
+}
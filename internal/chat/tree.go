@@ -0,0 +1,119 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Node is a message in a session's conversation tree
+type Node struct {
+	ID int `json:"id"`
+	// Parent is the ID of the message this one follows, 0 for the first
+	Parent  int     `json:"parent,omitempty"`
+	Message Message `json:"message"`
+}
+
+// tree holds every message of a session. Undoing an exchange or retrying an
+// answer moves Head, the last message of the active branch, so the replaced
+// messages stay in the tree as another branch. The path from the root to
+// Head is the history sent to the model.
+type tree struct {
+	Head  int    `json:"head"`
+	Nodes []Node `json:"nodes"`
+}
+
+// decodeTree reads a session file, which holds either a tree or, as saved
+// before sessions were trees, a list of messages
+func decodeTree(data []byte) (tree, error) {
+	var t tree
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '[' {
+		var messages []Message
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return t, err
+		}
+		for _, msg := range messages {
+			t.Head = t.add(t.Head, msg)
+		}
+		return t, nil
+	}
+	err := json.Unmarshal(data, &t)
+	return t, err
+}
+
+// node returns the node with the given ID, or nil if there is none
+func (t *tree) node(id int) *Node {
+	for i := range t.Nodes {
+		if t.Nodes[i].ID == id {
+			return &t.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// add adds a message after parent and returns its ID
+func (t *tree) add(parent int, msg Message) int {
+	id := t.maxID() + 1
+	t.Nodes = append(t.Nodes, Node{ID: id, Parent: parent, Message: msg})
+	return id
+}
+
+func (t *tree) maxID() int {
+	highest := 0
+	for _, node := range t.Nodes {
+		highest = max(highest, node.ID)
+	}
+	return highest
+}
+
+// path returns the nodes from the root to the node with the given ID
+func (t *tree) path(id int) []Node {
+	byID := make(map[int]Node, len(t.Nodes))
+	for _, node := range t.Nodes {
+		byID[node.ID] = node
+	}
+
+	var path []Node
+	for node, ok := byID[id]; ok; node, ok = byID[node.Parent] {
+		path = append(path, node)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// messages returns the messages of the active branch
+func (t *tree) messages() []Message {
+	path := t.path(t.Head)
+	messages := make([]Message, len(path))
+	for i, node := range path {
+		messages[i] = node.Message
+	}
+	return messages
+}
+
+// hasChildren reports whether any message follows the given one
+func (t *tree) hasChildren(id int) bool {
+	for _, node := range t.Nodes {
+		if node.Parent == id {
+			return true
+		}
+	}
+	return false
+}
+
+// prune removes the given nodes, deepest first, where no other message
+// follows them
+func (t *tree) prune(ids []int) {
+	for i := len(ids) - 1; i >= 0; i-- {
+		if t.hasChildren(ids[i]) {
+			continue
+		}
+		for j, node := range t.Nodes {
+			if node.ID == ids[i] {
+				t.Nodes = append(t.Nodes[:j], t.Nodes[j+1:]...)
+				break
+			}
+		}
+	}
+}
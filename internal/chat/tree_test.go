@@ -0,0 +1,166 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gopilot/internal/config"
+	"gopilot/internal/providers"
+)
+
+// pathTexts returns the messages of the session's active branch as text
+func pathTexts(s *Session) string {
+	var texts []string
+	for _, msg := range s.GetHistory() {
+		texts = append(texts, msg.Content.Text())
+	}
+	return strings.Join(texts, " | ")
+}
+
+// numberedAnswers answers each request with the question and how many
+// requests came before it, so retried answers differ
+func numberedAnswers() func(providers.Request) (*providers.Response, error) {
+	n := 0
+	return func(request providers.Request) (*providers.Response, error) {
+		n++
+		// A retry sends no message, continuing from the last user turn
+		var question string
+		if request.Message != nil {
+			question = request.Message.Text()
+		} else {
+			question = request.History[len(request.History)-1].Content.Text()
+		}
+		return &providers.Response{Content: question + " answered " + string(rune('0'+n))}, nil
+	}
+}
+
+func TestUndoAndRetry(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	s := newTestSession(t, &config.Config{}, "tree", numberedAnswers())
+	for _, question := range []string{"q1", "q2"} {
+		if _, err := s.Send(context.Background(), question, Options{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := LoadSession("tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pathTexts(saved); got != "q1 | q1 answered 1" {
+		t.Errorf("after undo the saved branch is %q", got)
+	}
+
+	if _, err := s.Retry(context.Background(), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	saved, err = LoadSession("tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pathTexts(saved); got != "q1 | q1 answered 3" {
+		t.Errorf("after retry the saved branch is %q", got)
+	}
+	// The undone exchange and the replaced answer stay in the tree
+	if len(saved.Nodes()) != 5 {
+		t.Errorf("tree has %d nodes, want 5 with the old branches", len(saved.Nodes()))
+	}
+
+	// Undoing everything leaves nothing to retry
+	for i := 0; i < 2; i++ {
+		s.Undo()
+	}
+	if _, err := s.Retry(context.Background(), Options{}); err == nil {
+		t.Error("retry succeeded with an empty branch")
+	}
+}
+
+func TestForkSession(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	s := newTestSession(t, &config.Config{}, "main", numberedAnswers())
+	for _, question := range []string{"q1", "q2"} {
+		if _, err := s.Send(context.Background(), question, Options{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	firstAnswer := s.Path()[1].ID
+
+	if err := ForkSession("main", "whole", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := ForkSession("main", "start", firstAnswer); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"whole": "q1 | q1 answered 1 | q2 | q2 answered 2",
+		"start": "q1 | q1 answered 1",
+	} {
+		fork, err := LoadSession(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := pathTexts(fork); got != want {
+			t.Errorf("fork %s holds %q, want %q", name, got, want)
+		}
+	}
+
+	if err := ForkSession("main", "start", 0); err == nil {
+		t.Error("forking onto an existing session succeeded")
+	}
+	if err := ForkSession("main", "missing", 99); err == nil {
+		t.Error("forking at a missing message succeeded")
+	}
+}
+
+func TestMergeConcurrentSaves(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	first := newTestSession(t, &config.Config{}, "shared", echoAnswer)
+	if _, err := first.Send(context.Background(), "q0", Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both load the same history; the second inserts a system prompt before
+	// it, as an action does, and saves after the first
+	second := newTestSession(t, &config.Config{}, "shared", echoAnswer)
+	second.SetHistory(append([]providers.Message{{Role: "system", Content: providers.TextContent("prompt")}}, second.FormatHistoryForProvider()...))
+	if _, err := first.Send(context.Background(), "q1", Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.Send(context.Background(), "q2", Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := LoadSession("shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "prompt | q0 | answer to q0 | q1 | answer to q1 | q2 | answer to q2"
+	if got := pathTexts(saved); got != want {
+		t.Errorf("merged branch is %q, want %q", got, want)
+	}
+	if len(saved.Nodes()) != 7 {
+		t.Errorf("merged tree has %d nodes, want 7", len(saved.Nodes()))
+	}
+}
+
+func TestSetHistoryKeepsExistingMessages(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	s := newTestSession(t, &config.Config{}, "prompt", echoAnswer)
+	if _, err := s.Send(context.Background(), "q0", Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	prompt := providers.Message{Role: "system", Content: providers.TextContent("prompt")}
+	s.SetHistory(append([]providers.Message{prompt}, s.FormatHistoryForProvider()...))
+	s.SetHistory(s.FormatHistoryForProvider())
+	if got := pathTexts(s); got != "prompt | q0 | answer to q0" {
+		t.Errorf("branch is %q, want the prompt before the conversation", got)
+	}
+	if len(s.Nodes()) != 3 {
+		t.Errorf("tree has %d nodes, want the existing messages reused", len(s.Nodes()))
+	}
+}
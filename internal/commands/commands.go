@@ -0,0 +1,20 @@
+package commands
+
+import "context"
+
+// Command runs a gopilot subcommand with the arguments that follow its name
+type Command func(ctx context.Context, args []string) error
+
+// Registry stores all available subcommands
+var registry = make(map[string]Command)
+
+// Register adds a subcommand to the registry
+func Register(name string, command Command) {
+	registry[name] = command
+}
+
+// Get retrieves a subcommand from the registry
+func Get(name string) (Command, bool) {
+	command, exists := registry[name]
+	return command, exists
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"gopilot/internal/chat"
+	"gopilot/internal/config"
+	"gopilot/internal/providers"
+)
+
+func init() {
+	Register("undo", Undo)
+	Register("retry", Retry)
+}
+
+// Undo drops the last exchange of a session. It stays in the session's
+// tree, where sessions fork can still reach it.
+func Undo(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("undo", flag.ContinueOnError)
+	sessionFlag := fs.String("session", chat.DefaultSessionName, "Name of the conversation")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	session, err := chat.LoadSession(*sessionFlag)
+	if err != nil {
+		return err
+	}
+	return session.Undo()
+}
+
+// Retry regenerates the last answer of a session, keeping the previous
+// answer as another branch
+func Retry(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("retry", flag.ContinueOnError)
+	sessionFlag := fs.String("session", chat.DefaultSessionName, "Name of the conversation")
+	configFlag := fs.String("config", "", "Configuration file path")
+	cFlag := fs.String("c", "", "Configuration file path (shorthand)")
+	streamFlag := fs.Bool("stream", false, "Stream the response")
+	sFlag := fs.Bool("s", false, "Stream the response (shorthand)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *configFlag == "" && *cFlag != "" {
+		configFlag = cFlag
+	}
+
+	cfg, err := config.Load(*configFlag)
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+	session, err := chat.NewSession(cfg, *sessionFlag)
+	if err != nil {
+		return err
+	}
+	if backup := session.CorruptBackup(); backup != "" {
+		return fmt.Errorf("session history could not be read and was moved to %s", backup)
+	}
+
+	var opts chat.Options
+	if *streamFlag || *sFlag {
+		opts.Sink = providers.WriterSink(os.Stdout)
+	}
+	reply, err := session.Retry(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if opts.Sink == nil {
+		fmt.Println(reply.Content)
+	}
+	return nil
+}
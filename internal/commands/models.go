@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopilot/internal/config"
+	"gopilot/internal/providers"
+)
+
+func init() {
+	Register("models", Models)
+}
+
+// Models lists the catalog entries for a provider and, with --remote, the
+// models reported by the provider's own API
+func Models(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("models", flag.ContinueOnError)
+	remoteFlag := fs.Bool("remote", false, "Also query the provider's models endpoint")
+	allFlag := fs.Bool("all", false, "List the catalog for every provider")
+	configFlag := fs.String("config", "", "Configuration file path")
+	cFlag := fs.String("c", "", "Configuration file path (shorthand)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *configFlag == "" && *cFlag != "" {
+		configFlag = cFlag
+	}
+
+	cfg, err := config.Load(*configFlag)
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+	providers.RegisterModels(cfg.Provider, cfg.Models)
+
+	providerName := cfg.Provider
+	if fs.NArg() > 0 {
+		providerName = fs.Arg(0)
+	}
+
+	if !isProvider(providerName) {
+		return fmt.Errorf("unknown provider: %q. Known providers are: %s", providerName, strings.Join(providers.Names(), ", "))
+	}
+
+	names := []string{providers.CanonicalName(providerName)}
+	if *allFlag {
+		names = providers.CatalogProviders()
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tMODEL\tCONTEXT\tMAX OUTPUT\t$/1M IN\t$/1M OUT\tSTREAM\tTOOLS\tVISION")
+	for _, name := range names {
+		for _, model := range providers.Models(name) {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%.3f\t%.3f\t%s\t%s\t%s\n",
+				name, model.Name, model.ContextWindow, model.MaxOutputTokens,
+				model.InputPrice, model.OutputPrice,
+				yesNo(model.Streaming), yesNo(model.Tools), yesNo(model.Vision))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if !*remoteFlag {
+		return nil
+	}
+
+	opts, err := remoteOptions(cfg, providerName)
+	if err != nil {
+		return err
+	}
+	provider, err := providers.New(providerName, opts)
+	if err != nil {
+		return err
+	}
+
+	lister, ok := provider.(providers.ModelLister)
+	if !ok {
+		return fmt.Errorf("provider %s does not support listing models", providerName)
+	}
+	remote, err := lister.ListModels(ctx)
+	if err != nil {
+		return fmt.Errorf("listing models: %w", err)
+	}
+	sort.Strings(remote)
+
+	fmt.Printf("\nModels reported by %s:\n", providerName)
+	for _, name := range remote {
+		marker := ""
+		if _, known := providers.LookupModel(providerName, name); !known {
+			marker = " (not in catalog)"
+		}
+		fmt.Printf("  %s%s\n", name, marker)
+	}
+	return nil
+}
+
+// remoteOptions returns the options for querying providerName. The
+// configured key, endpoint and headers belong to the configured provider, so
+// another provider starts from scratch with the key from its own environment
+// variable.
+func remoteOptions(cfg *config.Config, providerName string) (providers.Options, error) {
+	opts, err := cfg.ProviderOptions()
+	if err != nil {
+		return opts, err
+	}
+	if providers.CanonicalName(providerName) != providers.CanonicalName(cfg.Provider) {
+		opts = providers.Options{Retry: opts.Retry}
+		if env := providers.APIKeyEnv(providerName); env != "" {
+			if opts.APIKey = os.Getenv(env); opts.APIKey == "" {
+				return opts, fmt.Errorf("the configured API key is for %s; set %s to list %s models", cfg.Provider, env, providerName)
+			}
+		}
+	}
+	opts.AllowUnknownModel = true
+	return opts, nil
+}
+
+func isProvider(name string) bool {
+	canonical := providers.CanonicalName(name)
+	for _, known := range providers.Names() {
+		if known == canonical {
+			return true
+		}
+	}
+	return false
+}
+
+func yesNo(v bool) string {
+	if v {
+		return "yes"
+	}
+	return "no"
+}
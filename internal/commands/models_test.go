@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"testing"
+
+	"gopilot/internal/config"
+)
+
+func TestRemoteOptionsKeepOtherProvidersFromTheConfiguredKey(t *testing.T) {
+	cfg := &config.Config{
+		Provider: "openai",
+		APIKey:   "sk-openai",
+		Model:    "gpt-4o",
+		BaseURL:  "https://gateway.example.com/v1",
+		Headers:  map[string]string{"X-Team": "tools"},
+	}
+
+	opts, err := remoteOptions(cfg, "openai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.APIKey != "sk-openai" || opts.BaseURL != cfg.BaseURL || opts.Model != "gpt-4o" {
+		t.Errorf("options for the configured provider = %+v, want the configured ones", opts)
+	}
+
+	t.Setenv("GEMINI_API_KEY", "")
+	if _, err := remoteOptions(cfg, "gemini"); err == nil {
+		t.Error("listing another provider's models without its key succeeded")
+	}
+
+	t.Setenv("GEMINI_API_KEY", "gemini-key")
+	opts, err = remoteOptions(cfg, "google")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.APIKey != "gemini-key" || opts.BaseURL != "" || opts.Headers != nil || opts.Model != "" {
+		t.Errorf("options for another provider = %+v, want only its own key", opts)
+	}
+
+	// Local providers need no key
+	if opts, err := remoteOptions(cfg, "ollama"); err != nil || opts.APIKey != "" {
+		t.Errorf("options for ollama = %+v, %v, want no key", opts, err)
+	}
+}
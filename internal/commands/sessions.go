@@ -0,0 +1,191 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"gopilot/internal/chat"
+	"gopilot/internal/providers"
+)
+
+func init() {
+	Register("sessions", Sessions)
+}
+
+// Sessions manages the stored conversations: list, show, tree, fork, rm and
+// rename
+func Sessions(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return listSessions()
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		return listSessions()
+	case "show":
+		return showSession(args[1:])
+	case "tree":
+		return showTree(args[1:])
+	case "fork":
+		return forkSession(args[1:])
+	case "rm":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: gopilot sessions rm NAME...")
+		}
+		for _, name := range args[1:] {
+			if err := chat.RemoveSession(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "rename", "mv":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: gopilot sessions rename OLD NEW")
+		}
+		return chat.RenameSession(args[1], args[2])
+	default:
+		return fmt.Errorf("unknown sessions command %q: use list, show, tree, fork, rm or rename", args[0])
+	}
+}
+
+func listSessions() error {
+	sessions, err := chat.ListSessions()
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		fmt.Println("No sessions")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tMESSAGES\tUPDATED")
+	for _, session := range sessions {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", session.Name, session.Messages, session.Updated.Local().Format("2006-01-02 15:04"))
+	}
+	return w.Flush()
+}
+
+func showSession(args []string) error {
+	fs := flag.NewFlagSet("sessions show", flag.ContinueOnError)
+	lastFlag := fs.Int("last", 0, "Only show the last N messages")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	name := chat.DefaultSessionName
+	if fs.NArg() > 0 {
+		name = fs.Arg(0)
+	}
+	session, err := chat.LoadSession(name)
+	if err != nil {
+		return err
+	}
+
+	path := session.Path()
+	if *lastFlag > 0 && *lastFlag < len(path) {
+		path = path[len(path)-*lastFlag:]
+	}
+	for i, node := range path {
+		if i > 0 {
+			fmt.Println()
+		}
+		msg := node.Message
+		if msg.Archive != nil {
+			fmt.Printf("[#%d %s, summary of %d messages archived in %s]\n%s\n", node.ID, msg.Role, msg.Archive.Messages, session.ArchivePath(msg.Archive), describeContent(msg.Content))
+			continue
+		}
+		fmt.Printf("[#%d %s]\n%s\n", node.ID, msg.Role, describeContent(msg.Content))
+	}
+	return nil
+}
+
+// showTree prints every branch of a session, one line per message, marking
+// the active branch with *
+func showTree(args []string) error {
+	name := chat.DefaultSessionName
+	if len(args) > 0 {
+		name = args[0]
+	}
+	session, err := chat.LoadSession(name)
+	if err != nil {
+		return err
+	}
+
+	active := make(map[int]bool)
+	for _, node := range session.Path() {
+		active[node.ID] = true
+	}
+	children := make(map[int][]chat.Node)
+	for _, node := range session.Nodes() {
+		children[node.Parent] = append(children[node.Parent], node)
+	}
+
+	// Messages are only indented where the conversation branches, and the
+	// first message of each branch is marked with -
+	var printBranch func(parent int, indent string)
+	printBranch = func(parent int, indent string) {
+		branches := children[parent]
+		for _, node := range branches {
+			marker, lead, next := " ", indent, indent
+			if active[node.ID] {
+				marker = "*"
+			}
+			if len(branches) > 1 {
+				lead, next = indent+"- ", indent+"  "
+			}
+			fmt.Printf("%s %s#%d %s: %s\n", marker, lead, node.ID, node.Message.Role, snippet(describeContent(node.Message.Content), 60))
+			printBranch(node.ID, next)
+		}
+	}
+	printBranch(0, "")
+	return nil
+}
+
+// snippet returns the first line of text, cut to at most n characters
+func snippet(text string, n int) string {
+	text, _, _ = strings.Cut(text, "\n")
+	if runes := []rune(text); len(runes) > n {
+		return string(runes[:n-3]) + "..."
+	}
+	return text
+}
+
+func forkSession(args []string) error {
+	fs := flag.NewFlagSet("sessions fork", flag.ContinueOnError)
+	atFlag := fs.Int("at", 0, "ID of the last message to copy, as shown by sessions show (default: the last message)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: gopilot sessions fork [--at ID] NAME NEW")
+	}
+	return chat.ForkSession(fs.Arg(0), fs.Arg(1), *atFlag)
+}
+
+// describeContent renders content for reading, summarising the parts that
+// have no text form
+func describeContent(content providers.Content) string {
+	var lines []string
+	for _, part := range content {
+		switch part.Type {
+		case providers.PartImage:
+			lines = append(lines, fmt.Sprintf("(image %s, %s, %d bytes)", part.Name, part.MIMEType, len(part.Data)))
+		case providers.PartFile:
+			lines = append(lines, fmt.Sprintf("(file %s, %s, %d bytes)", part.Name, part.MIMEType, len(part.Data)))
+		case providers.PartToolCall:
+			if part.ToolCall != nil {
+				lines = append(lines, fmt.Sprintf("(tool call %s %s)", part.ToolCall.Name, part.ToolCall.Arguments))
+			}
+		case providers.PartToolResult:
+			lines = append(lines, fmt.Sprintf("(result of %s)\n%s", part.ToolCallID, part.Text))
+		default:
+			lines = append(lines, providers.Content{part}.Text())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
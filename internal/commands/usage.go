@@ -0,0 +1,165 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopilot/internal/usage"
+)
+
+func init() {
+	Register("usage", Usage)
+}
+
+// usageTotals accumulates the ledger entries that share a grouping key
+type usageTotals struct {
+	key              []string
+	requests         int
+	promptTokens     int
+	completionTokens int
+	cost             float64
+	estimated        bool
+}
+
+// Usage summarizes the usage ledger grouped by day, model and/or action
+func Usage(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("usage", flag.ContinueOnError)
+	byFlag := fs.String("by", "day", "Comma-separated grouping: day, model and/or action")
+	sinceFlag := fs.String("since", "", "Only include requests since a date (2006-01-02) or a number of days ago (7d)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	groups := strings.Split(*byFlag, ",")
+	for i, group := range groups {
+		groups[i] = strings.TrimSpace(group)
+		switch groups[i] {
+		case "day", "model", "action":
+		default:
+			return fmt.Errorf("invalid grouping %q: use day, model or action", group)
+		}
+	}
+
+	since, err := parseSince(*sinceFlag)
+	if err != nil {
+		return err
+	}
+
+	entries, err := usage.Load()
+	if err != nil {
+		return fmt.Errorf("reading usage ledger: %w", err)
+	}
+
+	rows, grand := groupUsage(entries, groups, since)
+	if grand.requests == 0 {
+		fmt.Println("No usage recorded")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	header := make([]string, len(groups))
+	for i, group := range groups {
+		header[i] = strings.ToUpper(group)
+	}
+	fmt.Fprintf(w, "%s\tREQUESTS\tPROMPT\tCOMPLETION\tCOST (USD)\n", strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintf(w, "%s\t%s\n", strings.Join(row.key, "\t"), row.columns())
+	}
+	grand.key = make([]string, len(groups))
+	grand.key[0] = "TOTAL"
+	fmt.Fprintf(w, "%s\t%s\n", strings.Join(grand.key, "\t"), grand.columns())
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if grand.estimated {
+		fmt.Println("\n* includes token counts estimated where the provider did not report usage")
+	}
+	return nil
+}
+
+// groupUsage totals the entries recorded at or after since by the values of
+// groups, returning the rows sorted by key and the grand total
+func groupUsage(entries []usage.Entry, groups []string, since time.Time) ([]*usageTotals, usageTotals) {
+	totals := make(map[string]*usageTotals)
+	var grand usageTotals
+	for _, entry := range entries {
+		if entry.Time.Before(since) {
+			continue
+		}
+
+		key := make([]string, len(groups))
+		for i, group := range groups {
+			key[i] = groupValue(entry, group)
+		}
+		id := strings.Join(key, "\x00")
+		if totals[id] == nil {
+			totals[id] = &usageTotals{key: key}
+		}
+		totals[id].add(entry)
+		grand.add(entry)
+	}
+
+	rows := make([]*usageTotals, 0, len(totals))
+	for _, row := range totals {
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return strings.Join(rows[i].key, "\x00") < strings.Join(rows[j].key, "\x00")
+	})
+	return rows, grand
+}
+
+func (t *usageTotals) add(entry usage.Entry) {
+	t.requests++
+	t.promptTokens += entry.PromptTokens
+	t.completionTokens += entry.CompletionTokens
+	t.cost += entry.Cost
+	t.estimated = t.estimated || entry.Estimated
+}
+
+func (t *usageTotals) columns() string {
+	marker := ""
+	if t.estimated {
+		marker = "*"
+	}
+	return fmt.Sprintf("%d\t%d%s\t%d%s\t%.4f", t.requests, t.promptTokens, marker, t.completionTokens, marker, t.cost)
+}
+
+func groupValue(entry usage.Entry, group string) string {
+	switch group {
+	case "day":
+		return entry.Time.Local().Format("2006-01-02")
+	case "model":
+		return entry.Provider + "/" + entry.Model
+	default:
+		if entry.Action == "" {
+			return "-"
+		}
+		return entry.Action
+	}
+}
+
+// parseSince accepts a date or a number of days such as 7d; empty means all time
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && strings.HasSuffix(value, "d") {
+		now := time.Now()
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		return midnight.AddDate(0, 0, -days+1), nil
+	}
+	since, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since value %q: use a date (2006-01-02) or days (7d)", value)
+	}
+	return since, nil
+}
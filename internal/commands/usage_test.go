@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gopilot/internal/usage"
+)
+
+func TestGroupUsage(t *testing.T) {
+	day := func(d int, hour int) time.Time {
+		return time.Date(2025, time.March, d, hour, 0, 0, 0, time.Local)
+	}
+	entries := []usage.Entry{
+		{Time: day(1, 9), Provider: "openai", Model: "gpt-4o", PromptTokens: 100, CompletionTokens: 10, Cost: 0.5},
+		{Time: day(2, 9), Provider: "openai", Model: "gpt-4o", Action: "summary", PromptTokens: 200, CompletionTokens: 20, Cost: 1},
+		{Time: day(2, 10), Provider: "openai", Model: "gpt-4o", PromptTokens: 300, CompletionTokens: 30, Cost: 1.5, Estimated: true},
+		{Time: day(3, 9), Provider: "anthropic", Model: "claude-3-5-haiku-latest", PromptTokens: 400, CompletionTokens: 40, Cost: 2},
+	}
+
+	rows, grand := groupUsage(entries, []string{"model", "action"}, day(2, 0))
+	var keys [][]string
+	for _, row := range rows {
+		keys = append(keys, row.key)
+	}
+	want := [][]string{
+		{"anthropic/claude-3-5-haiku-latest", "-"},
+		{"openai/gpt-4o", "-"},
+		{"openai/gpt-4o", "summary"},
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("rows = %q, want %q", keys, want)
+	}
+	if row := rows[1]; row.requests != 1 || row.promptTokens != 300 || !row.estimated {
+		t.Errorf("openai row = %+v, want the estimated request of March 2", row)
+	}
+	if grand.requests != 3 || grand.promptTokens != 900 || grand.completionTokens != 90 || grand.cost != 4.5 || !grand.estimated {
+		t.Errorf("total = %+v, want the three requests since March 2", grand)
+	}
+
+	rows, _ = groupUsage(entries, []string{"day"}, time.Time{})
+	if len(rows) != 3 || rows[1].key[0] != "2025-03-02" || rows[1].requests != 2 {
+		t.Errorf("rows by day = %+v, want three days with two requests on March 2", rows)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	since, err := parseSince("2025-03-02")
+	if err != nil || !since.Equal(time.Date(2025, time.March, 2, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("parseSince(2025-03-02) = %v, %v", since, err)
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if since, err := parseSince("1d"); err != nil || !since.Equal(today) {
+		t.Errorf("parseSince(1d) = %v, %v, want the start of today", since, err)
+	}
+	if since, err := parseSince("7d"); err != nil || !since.Equal(today.AddDate(0, 0, -6)) {
+		t.Errorf("parseSince(7d) = %v, %v, want six days before today", since, err)
+	}
+	if _, err := parseSince("last week"); err == nil {
+		t.Error("parseSince accepted an invalid value")
+	}
+}
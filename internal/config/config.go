@@ -2,9 +2,14 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	"gopilot/internal/providers"
 
 	"gopkg.in/yaml.v2"
 )
@@ -13,6 +18,98 @@ type Config struct {
 	Provider string `json:"PROVIDER" yaml:"PROVIDER"`
 	APIKey   string `json:"API_KEY" yaml:"API_KEY"`
 	Model    string `json:"MODEL" yaml:"MODEL"`
+
+	// BaseURL points the provider at a non-default endpoint, e.g. a self-hosted TGI server
+	BaseURL string `json:"BASE_URL" yaml:"BASE_URL"`
+	// ChatTemplate selects the prompt format for raw text-generation endpoints
+	ChatTemplate string `json:"CHAT_TEMPLATE" yaml:"CHAT_TEMPLATE"`
+
+	// Headers are extra HTTP headers sent with every provider request
+	Headers      map[string]string `json:"HEADERS" yaml:"HEADERS"`
+	Organization string            `json:"ORGANIZATION" yaml:"ORGANIZATION"`
+	Project      string            `json:"PROJECT" yaml:"PROJECT"`
+	APIVersion   string            `json:"API_VERSION" yaml:"API_VERSION"`
+	// Deployment is the Azure OpenAI deployment name
+	Deployment string `json:"DEPLOYMENT" yaml:"DEPLOYMENT"`
+	// StreamUsage asks OpenAI-style servers other than OpenAI itself to report
+	// usage in streamed responses, which not every server accepts
+	StreamUsage bool `json:"STREAM_USAGE" yaml:"STREAM_USAGE"`
+
+	// Models adds or overrides model catalog entries for the configured provider
+	Models            map[string]providers.ModelInfo `json:"MODELS" yaml:"MODELS"`
+	AllowUnknownModel bool                           `json:"ALLOW_UNKNOWN_MODEL" yaml:"ALLOW_UNKNOWN_MODEL"`
+
+	// Retry controls retries of rate limited and failed provider requests
+	Retry RetryConfig `json:"RETRY" yaml:"RETRY"`
+
+	// Budget limits what a single invocation, and each day, may spend
+	Budget BudgetConfig `json:"BUDGET" yaml:"BUDGET"`
+
+	// Sampling holds the default generation parameters (TEMPERATURE, TOP_P,
+	// SEED, MAX_TOKENS and STOP), set at the top level of the config
+	providers.Sampling `yaml:",inline"`
+	// Actions overrides settings for individual actions, keyed by action name
+	Actions map[string]ActionConfig `json:"ACTIONS" yaml:"ACTIONS"`
+
+	// Agent controls the tool loop run for actions that expose tools
+	Agent AgentConfig `json:"AGENT" yaml:"AGENT"`
+
+	// Summary controls compressing old turns of long sessions into a summary
+	Summary SummaryConfig `json:"SUMMARY" yaml:"SUMMARY"`
+
+	// MaxContextTokens caps the tokens of history sent with each request;
+	// zero uses the model's context window from the catalog
+	MaxContextTokens int `json:"MAX_CONTEXT_TOKENS" yaml:"MAX_CONTEXT_TOKENS"`
+
+	// SchemaRetries is how many times a response that does not match the
+	// requested JSON Schema is sent back for repair; nil uses the default
+	// of 2 and 0 turns repair off
+	SchemaRetries *int `json:"SCHEMA_RETRIES" yaml:"SCHEMA_RETRIES"`
+}
+
+// AgentConfig holds the limits of the agent tool loop. CommandTimeout is a
+// duration such as "2m"; unset values fall back to the defaults.
+type AgentConfig struct {
+	MaxIterations int `json:"MAX_ITERATIONS" yaml:"MAX_ITERATIONS"`
+	// AllowedCommands are the command prefixes the model may run, e.g. "go test"
+	AllowedCommands []string `json:"ALLOWED_COMMANDS" yaml:"ALLOWED_COMMANDS"`
+	CommandTimeout  string   `json:"COMMAND_TIMEOUT" yaml:"COMMAND_TIMEOUT"`
+}
+
+// SummaryConfig controls when and how old turns are summarized. A zero
+// Threshold disables summaries; other unset values fall back to the defaults.
+type SummaryConfig struct {
+	// Threshold is the size of the history, in tokens, that triggers a summary
+	Threshold int `json:"THRESHOLD" yaml:"THRESHOLD"`
+	// KeepTurns is how many recent exchanges are kept word for word
+	KeepTurns int `json:"KEEP_TURNS" yaml:"KEEP_TURNS"`
+	// Model writes the summaries, defaulting to MODEL; a cheaper model is usually enough
+	Model     string `json:"MODEL" yaml:"MODEL"`
+	MaxTokens int    `json:"MAX_TOKENS" yaml:"MAX_TOKENS"`
+}
+
+// ActionConfig holds the settings applied when a specific action runs
+type ActionConfig struct {
+	providers.Sampling `yaml:",inline"`
+	// Schema is the path of a JSON Schema the action's responses must match
+	Schema string `json:"SCHEMA" yaml:"SCHEMA"`
+}
+
+// RetryConfig holds the retry policy. Delays are durations such as "500ms" or "30s",
+// and unset values fall back to the defaults.
+type RetryConfig struct {
+	MaxAttempts int    `json:"MAX_ATTEMPTS" yaml:"MAX_ATTEMPTS"`
+	BaseDelay   string `json:"BASE_DELAY" yaml:"BASE_DELAY"`
+	MaxDelay    string `json:"MAX_DELAY" yaml:"MAX_DELAY"`
+}
+
+// BudgetConfig holds the spending limits checked before each request is sent.
+// Zero disables a limit. Costs are in USD, estimated from the model catalog.
+type BudgetConfig struct {
+	MaxInputTokens  int     `json:"MAX_INPUT_TOKENS" yaml:"MAX_INPUT_TOKENS"`
+	MaxOutputTokens int     `json:"MAX_OUTPUT_TOKENS" yaml:"MAX_OUTPUT_TOKENS"`
+	MaxCost         float64 `json:"MAX_COST" yaml:"MAX_COST"`
+	MaxDailyCost    float64 `json:"MAX_DAILY_COST" yaml:"MAX_DAILY_COST"`
 }
 
 func Load(configPath string) (*Config, error) {
@@ -37,6 +134,49 @@ func Load(configPath string) (*Config, error) {
 	return loadFromEnv(), nil
 }
 
+// ProviderOptions returns the settings used to construct the configured provider
+func (c *Config) ProviderOptions() (providers.Options, error) {
+	retry, err := c.Retry.policy()
+	if err != nil {
+		return providers.Options{}, err
+	}
+
+	return providers.Options{
+		APIKey:            c.APIKey,
+		Model:             c.Model,
+		BaseURL:           c.BaseURL,
+		ChatTemplate:      c.ChatTemplate,
+		Headers:           c.Headers,
+		Organization:      c.Organization,
+		Project:           c.Project,
+		APIVersion:        c.APIVersion,
+		Deployment:        c.Deployment,
+		AllowUnknownModel: c.AllowUnknownModel,
+		StreamUsage:       c.StreamUsage,
+		Retry:             retry,
+	}, nil
+}
+
+func (r RetryConfig) policy() (providers.RetryPolicy, error) {
+	policy := providers.DefaultRetryPolicy
+	if r.MaxAttempts > 0 {
+		policy.MaxAttempts = r.MaxAttempts
+	}
+
+	var err error
+	if r.BaseDelay != "" {
+		if policy.BaseDelay, err = time.ParseDuration(r.BaseDelay); err != nil {
+			return policy, fmt.Errorf("invalid RETRY BASE_DELAY: %w", err)
+		}
+	}
+	if r.MaxDelay != "" {
+		if policy.MaxDelay, err = time.ParseDuration(r.MaxDelay); err != nil {
+			return policy, fmt.Errorf("invalid RETRY MAX_DELAY: %w", err)
+		}
+	}
+	return policy, nil
+}
+
 func loadFromFile(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -55,6 +195,10 @@ func loadFromFile(path string) (*Config, error) {
 		err = json.Unmarshal(data, cfg)
 	}
 
+	if cfg.Provider == "" {
+		cfg.Provider = "openai"
+	}
+
 	return cfg, err
 }
 
@@ -79,19 +223,74 @@ func loadFromEnv() *Config {
 		Provider: os.Getenv("PROVIDER"),
 		APIKey:   os.Getenv("API_KEY"),
 		Model:    os.Getenv("MODEL"),
+
+		BaseURL:      os.Getenv("BASE_URL"),
+		ChatTemplate: os.Getenv("CHAT_TEMPLATE"),
+
+		Headers:      parseHeaders(os.Getenv("HEADERS")),
+		Organization: os.Getenv("ORGANIZATION"),
+		Project:      os.Getenv("PROJECT"),
+		APIVersion:   os.Getenv("API_VERSION"),
+		Deployment:   os.Getenv("DEPLOYMENT"),
+	}
+	cfg.AllowUnknownModel, _ = strconv.ParseBool(os.Getenv("ALLOW_UNKNOWN_MODEL"))
+	cfg.StreamUsage, _ = strconv.ParseBool(os.Getenv("STREAM_USAGE"))
+	cfg.Retry.MaxAttempts, _ = strconv.Atoi(os.Getenv("RETRY_MAX_ATTEMPTS"))
+	cfg.Retry.BaseDelay = os.Getenv("RETRY_BASE_DELAY")
+	cfg.Retry.MaxDelay = os.Getenv("RETRY_MAX_DELAY")
+	cfg.Budget.MaxInputTokens, _ = strconv.Atoi(os.Getenv("BUDGET_MAX_INPUT_TOKENS"))
+	cfg.Budget.MaxOutputTokens, _ = strconv.Atoi(os.Getenv("BUDGET_MAX_OUTPUT_TOKENS"))
+	cfg.Budget.MaxCost, _ = strconv.ParseFloat(os.Getenv("BUDGET_MAX_COST"), 64)
+	cfg.Budget.MaxDailyCost, _ = strconv.ParseFloat(os.Getenv("BUDGET_MAX_DAILY_COST"), 64)
+	cfg.Sampling = samplingFromEnv()
+	cfg.Agent.MaxIterations, _ = strconv.Atoi(os.Getenv("AGENT_MAX_ITERATIONS"))
+	if commands := os.Getenv("AGENT_ALLOWED_COMMANDS"); commands != "" {
+		cfg.Agent.AllowedCommands = strings.Split(commands, ",")
+	}
+	cfg.Agent.CommandTimeout = os.Getenv("AGENT_COMMAND_TIMEOUT")
+	cfg.Summary.Threshold, _ = strconv.Atoi(os.Getenv("SUMMARY_THRESHOLD"))
+	cfg.Summary.KeepTurns, _ = strconv.Atoi(os.Getenv("SUMMARY_KEEP_TURNS"))
+	cfg.Summary.Model = os.Getenv("SUMMARY_MODEL")
+	cfg.Summary.MaxTokens, _ = strconv.Atoi(os.Getenv("SUMMARY_MAX_TOKENS"))
+	if value, err := strconv.Atoi(os.Getenv("SCHEMA_RETRIES")); err == nil {
+		cfg.SchemaRetries = &value
 	}
+	cfg.MaxContextTokens, _ = strconv.Atoi(os.Getenv("MAX_CONTEXT_TOKENS"))
 
-	// Add defaults if values are empty
+	// Default to OpenAI. The model is left empty for the provider to pick its
+	// own default, as a single default cannot suit every provider.
 	if cfg.Provider == "" {
 		cfg.Provider = "openai"
 	}
-	if cfg.Model == "" {
-		cfg.Model = "gpt-3.5-turbo"
-	}
 
 	return cfg
 }
 
+// samplingFromEnv reads the generation parameters, leaving unset or invalid values nil
+func samplingFromEnv() providers.Sampling {
+	var sampling providers.Sampling
+	if value, err := strconv.ParseFloat(os.Getenv("TEMPERATURE"), 64); err == nil {
+		sampling.Temperature = &value
+	}
+	if value, err := strconv.ParseFloat(os.Getenv("TOP_P"), 64); err == nil {
+		sampling.TopP = &value
+	}
+	if value, err := strconv.Atoi(os.Getenv("SEED")); err == nil {
+		sampling.Seed = &value
+	}
+	sampling.MaxTokens, _ = strconv.Atoi(os.Getenv("MAX_TOKENS"))
+	if stop := os.Getenv("STOP"); stop != "" {
+		sampling.Stop = strings.Split(stop, ",")
+	}
+	return sampling
+}
+
+// SamplingFor returns the generation parameters for an action, applying its
+// overrides to the defaults. An empty action returns the defaults.
+func (c *Config) SamplingFor(action string) providers.Sampling {
+	return c.Sampling.Merge(c.Actions[action].Sampling)
+}
+
 func loadDotEnv() error {
 	data, err := os.ReadFile(".env")
 	if err != nil {
@@ -119,6 +318,23 @@ func loadDotEnv() error {
 	return nil
 }
 
+// parseHeaders reads headers from a comma-separated list of Key=Value pairs
+func parseHeaders(value string) map[string]string {
+	if value == "" {
+		return nil
+	}
+
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return headers
+}
+
 func isValidConfig(cfg *Config) bool {
 	// Only API key is truly required since we have defaults for other fields
 	return cfg.APIKey != ""
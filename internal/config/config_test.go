@@ -0,0 +1,42 @@
+package config
+
+import "testing"
+
+func TestLoadFromEnvLeavesModelToProvider(t *testing.T) {
+	t.Setenv("PROVIDER", "ollama")
+	t.Setenv("MODEL", "")
+
+	cfg := loadFromEnv()
+	if cfg.Model != "" {
+		t.Errorf("Model = %q, want it empty for the provider's default", cfg.Model)
+	}
+	opts, err := cfg.ProviderOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Model != "" {
+		t.Errorf("provider options Model = %q, want it empty", opts.Model)
+	}
+}
+
+func TestLoadFromEnvDefaultsToOpenAI(t *testing.T) {
+	t.Setenv("PROVIDER", "")
+	t.Setenv("MODEL", "gpt-4o")
+
+	cfg := loadFromEnv()
+	if cfg.Provider != "openai" || cfg.Model != "gpt-4o" {
+		t.Errorf("provider = %q, model = %q, want openai and gpt-4o", cfg.Provider, cfg.Model)
+	}
+}
+
+func TestLoadFromEnvSchemaRetries(t *testing.T) {
+	t.Setenv("SCHEMA_RETRIES", "")
+	if cfg := loadFromEnv(); cfg.SchemaRetries != nil {
+		t.Errorf("SchemaRetries = %d, want it unset for the default", *cfg.SchemaRetries)
+	}
+
+	t.Setenv("SCHEMA_RETRIES", "0")
+	if cfg := loadFromEnv(); cfg.SchemaRetries == nil || *cfg.SchemaRetries != 0 {
+		t.Errorf("SchemaRetries = %v, want 0 to turn repair off", cfg.SchemaRetries)
+	}
+}
@@ -1,32 +1,370 @@
 package providers
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	anthropicBaseURL      = "https://api.anthropic.com/v1"
+	anthropicVersion      = "2023-06-01"
+	anthropicDefaultModel = "claude-3-5-sonnet-latest"
+	anthropicMaxTokens    = 4096
 )
 
 type Anthropic struct {
-	apiKey string
-	model  string
+	api   httpAPI
+	model string
+}
+
+type anthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []anthropicContentBlock `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+	MaxTokens int                `json:"max_tokens"`
+	Stream    bool               `json:"stream,omitempty"`
+	Tools     []anthropicTool    `json:"tools,omitempty"`
+
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"top_p,omitempty"`
+	StopSequences []string `json:"stop_sequences,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// anthropicContentBlock is a text, image, document, tool_use or tool_result block
+type anthropicContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+
+	Source *anthropicSource `json:"source,omitempty"`
+
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
+// anthropicSource holds the base64 data of an image or document block
+type anthropicSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      []byte `json:"data"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	Content    []anthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      anthropicUsage          `json:"usage"`
+}
+
+type anthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Index int    `json:"index"`
+	// ContentBlock opens a block on content_block_start
+	ContentBlock anthropicContentBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+	// Message carries the prompt usage on message_start
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	// Usage carries the running output token count on message_delta
+	Usage anthropicUsage `json:"usage"`
 }
 
-func NewAnthropic(apiKey, model string) (*Anthropic, error) {
+func init() {
+	Register("anthropic", nil, func(opts Options) (Provider, error) {
+		return NewAnthropic(opts)
+	})
+}
+
+func NewAnthropic(opts Options) (*Anthropic, error) {
+	model := opts.Model
 	if model == "" {
-		model = "claude-2"
+		model = anthropicDefaultModel
 	}
-	return &Anthropic{
-		apiKey: apiKey,
-		model:  model,
-	}, nil
+
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = anthropicBaseURL
+	}
+
+	header := http.Header{}
+	header.Set("x-api-key", opts.APIKey)
+	header.Set("anthropic-version", anthropicVersion)
+
+	a := &Anthropic{
+		api: httpAPI{
+			provider:    "anthropic",
+			baseURL:     baseURL,
+			header:      header,
+			decodeError: decodeAnthropicError,
+		},
+		model: model,
+	}
+	a.api.client = newRetryClient(nil, opts.Retry, a.HandleRateLimiting)
+
+	return a, nil
 }
 
-func (a *Anthropic) Send(history []Message, message interface{}, stream bool) (string, error) {
-	return "", fmt.Errorf("anthropic provider not yet implemented - requires anthropic-go client")
+func (a *Anthropic) Send(ctx context.Context, request Request, sink StreamSink) (*Response, error) {
+	system, messages := buildAnthropicMessages(request.History, request.Message)
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("anthropic: no user message to send")
+	}
+
+	// The Messages API requires max_tokens, so there is always a limit. Seed and
+	// JSON mode are not supported, so structured output relies on the prompt.
+	maxTokens := request.Sampling.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = anthropicMaxTokens
+	}
+
+	req := anthropicRequest{
+		Model:         a.model,
+		System:        system,
+		Messages:      messages,
+		MaxTokens:     maxTokens,
+		Stream:        sink != nil,
+		Temperature:   request.Sampling.Temperature,
+		TopP:          request.Sampling.TopP,
+		StopSequences: request.Sampling.Stop,
+	}
+	for _, tool := range request.Tools {
+		req.Tools = append(req.Tools, anthropicTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: tool.schemaOrEmpty(),
+		})
+	}
+
+	resp, err := a.api.post(ctx, "/messages", req)
+	if err != nil {
+		return nil, err
+	}
+
+	if sink != nil {
+		return a.handleStreamingResponse(ctx, resp, sink)
+	}
+	return a.handleSingleResponse(resp)
 }
 
 func (a *Anthropic) SupportsStreaming() bool {
-	return false
+	return true
+}
+
+func (a *Anthropic) Model() string {
+	return a.model
 }
 
 func (a *Anthropic) HandleRateLimiting(err error) error {
-	return err
+	return classifyError(err)
+}
+
+func (a *Anthropic) handleStreamingResponse(ctx context.Context, resp *http.Response, sink StreamSink) (*Response, error) {
+	defer resp.Body.Close()
+
+	reader := newSSEReader(resp.Body)
+	var fullResponse strings.Builder
+	var usage Usage
+	var toolCalls toolCallBuilder
+	// toolIndex maps content block indexes to tool call indexes
+	toolIndex := make(map[int]int)
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, sink.fail(ctx, err)
+		}
+		if event.Data == "" {
+			continue
+		}
+
+		var payload anthropicStreamEvent
+		if err := json.Unmarshal([]byte(event.Data), &payload); err != nil {
+			return nil, sink.fail(ctx, fmt.Errorf("anthropic: invalid stream event: %w", err))
+		}
+
+		switch payload.Type {
+		case "message_start":
+			usage.PromptTokens = payload.Message.Usage.InputTokens
+			usage.CompletionTokens = payload.Message.Usage.OutputTokens
+		case "message_delta":
+			usage.CompletionTokens = payload.Usage.OutputTokens
+		case "content_block_start":
+			if payload.ContentBlock.Type != "tool_use" {
+				continue
+			}
+			toolIndex[payload.Index] = len(toolCalls.calls)
+			delta := ToolCallDelta{Index: len(toolCalls.calls), ID: payload.ContentBlock.ID, Name: payload.ContentBlock.Name}
+			toolCalls.add(delta)
+			sink.toolCall(delta)
+		case "content_block_delta":
+			switch payload.Delta.Type {
+			case "text_delta":
+				sink.text(payload.Delta.Text)
+				fullResponse.WriteString(payload.Delta.Text)
+			case "input_json_delta":
+				delta := ToolCallDelta{Index: toolIndex[payload.Index], Arguments: payload.Delta.PartialJSON}
+				toolCalls.add(delta)
+				sink.toolCall(delta)
+			}
+		case "error":
+			return nil, sink.fail(ctx, &APIError{
+				Provider: "anthropic",
+				Type:     payload.Error.Type,
+				Message:  payload.Error.Message,
+			})
+		case "message_stop":
+			sink.usage(usage)
+			sink.done()
+			return &Response{Content: fullResponse.String(), ToolCalls: toolCalls.calls, Usage: usage}, nil
+		}
+	}
+	sink.usage(usage)
+	sink.done()
+	return &Response{Content: fullResponse.String(), ToolCalls: toolCalls.calls, Usage: usage}, nil
+}
+
+func (a *Anthropic) handleSingleResponse(resp *http.Response) (*Response, error) {
+	var result anthropicResponse
+	if err := decodeJSON(resp, &result); err != nil {
+		return nil, fmt.Errorf("anthropic: invalid response: %w", err)
+	}
+
+	var text strings.Builder
+	var toolCalls []ToolCall
+	for _, block := range result.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			toolCalls = append(toolCalls, ToolCall{ID: block.ID, Name: block.Name, Arguments: string(block.Input)})
+		}
+	}
+	return &Response{
+		Content:   text.String(),
+		ToolCalls: toolCalls,
+		Usage: Usage{
+			PromptTokens:     result.Usage.InputTokens,
+			CompletionTokens: result.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+// buildAnthropicMessages converts the history into the Messages API shape.
+// System entries are hoisted into the top-level system prompt, tool results
+// are sent in a user turn, and consecutive turns from the same role are
+// merged since the API requires user and assistant turns to alternate,
+// starting with the user.
+func buildAnthropicMessages(history []Message, message Content) (string, []anthropicMessage) {
+	var system []string
+	var messages []anthropicMessage
+
+	appendBlocks := func(role string, blocks ...anthropicContentBlock) {
+		if len(blocks) == 0 {
+			return
+		}
+		if len(messages) > 0 && messages[len(messages)-1].Role == role {
+			last := &messages[len(messages)-1]
+			last.Content = append(last.Content, blocks...)
+			return
+		}
+		messages = append(messages, anthropicMessage{Role: role, Content: blocks})
+	}
+	for _, msg := range history {
+		switch msg.Role {
+		case "system":
+			if content := msg.Content.Text(); content != "" {
+				system = append(system, content)
+			}
+		case "assistant":
+			// The conversation must open with a user turn
+			if len(messages) == 0 {
+				continue
+			}
+			appendBlocks("assistant", anthropicBlocks(msg.Content)...)
+		default:
+			appendBlocks("user", anthropicBlocks(msg.Content)...)
+		}
+	}
+	appendBlocks("user", anthropicBlocks(message)...)
+
+	return strings.Join(system, "\n\n"), messages
+}
+
+// anthropicBlocks maps content parts onto content blocks. Images and PDFs
+// are sent as base64 sources and the remaining parts as text.
+func anthropicBlocks(content Content) []anthropicContentBlock {
+	var blocks []anthropicContentBlock
+	for _, part := range content {
+		switch {
+		case part.Type == PartImage:
+			blocks = append(blocks, anthropicContentBlock{
+				Type:   "image",
+				Source: &anthropicSource{Type: "base64", MediaType: part.MIMEType, Data: part.Data},
+			})
+		case part.Type == PartFile && part.MIMEType == "application/pdf":
+			blocks = append(blocks, anthropicContentBlock{
+				Type:   "document",
+				Source: &anthropicSource{Type: "base64", MediaType: part.MIMEType, Data: part.Data},
+			})
+		case part.Type == PartToolCall && part.ToolCall != nil:
+			input := json.RawMessage(part.ToolCall.Arguments)
+			if len(input) == 0 {
+				input = json.RawMessage("{}")
+			}
+			blocks = append(blocks, anthropicContentBlock{Type: "tool_use", ID: part.ToolCall.ID, Name: part.ToolCall.Name, Input: input})
+		case part.Type == PartToolResult:
+			blocks = append(blocks, anthropicContentBlock{Type: "tool_result", ToolUseID: part.ToolCallID, Content: part.Text})
+		default:
+			if text, ok := part.text(); ok && text != "" {
+				blocks = append(blocks, anthropicContentBlock{Type: "text", Text: text})
+			}
+		}
+	}
+	return blocks
+}
+
+func decodeAnthropicError(body []byte) (string, string) {
+	var payload struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", ""
+	}
+	return payload.Error.Type, payload.Error.Message
 }
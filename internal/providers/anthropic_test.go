@@ -0,0 +1,173 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// newAnthropicServer serves the Messages API from handler, checking the
+// headers every request must carry
+func newAnthropicServer(t *testing.T, handler func(w http.ResponseWriter, req anthropicRequest)) *Anthropic {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" {
+			t.Errorf("path = %q, want /messages", r.URL.Path)
+		}
+		if got := r.Header.Get("x-api-key"); got != "test-key" {
+			t.Errorf("x-api-key = %q, want test-key", got)
+		}
+		if got := r.Header.Get("anthropic-version"); got != anthropicVersion {
+			t.Errorf("anthropic-version = %q, want %s", got, anthropicVersion)
+		}
+		var req anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		handler(w, req)
+	}))
+	t.Cleanup(server.Close)
+
+	a, err := NewAnthropic(Options{APIKey: "test-key", BaseURL: server.URL, Retry: RetryPolicy{MaxAttempts: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestAnthropicStreaming(t *testing.T) {
+	recorded, err := os.ReadFile("testdata/anthropic_stream.sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newAnthropicServer(t, func(w http.ResponseWriter, req anthropicRequest) {
+		if !req.Stream {
+			t.Error("stream was not requested")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write(recorded)
+	})
+
+	var text strings.Builder
+	var events []EventType
+	sink := func(event StreamEvent) {
+		events = append(events, event.Type)
+		if event.Type == EventTextDelta {
+			text.WriteString(event.Text)
+		}
+	}
+	resp, err := a.Send(context.Background(), Request{Message: TextContent("What is the weather in San Francisco?")}, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "Okay, let me check the weather."; resp.Content != want || text.String() != want {
+		t.Errorf("content = %q, streamed %q, want %q", resp.Content, text.String(), want)
+	}
+	if len(resp.ToolCalls) != 1 {
+		t.Fatalf("got %d tool calls, want 1", len(resp.ToolCalls))
+	}
+	call := resp.ToolCalls[0]
+	if call.ID != "toolu_01T1x1fJ34qAmk2tNTrN7Up6" || call.Name != "get_weather" || call.Arguments != `{"location": "San Francisco, CA"}` {
+		t.Errorf("tool call = %+v", call)
+	}
+	if resp.Usage.PromptTokens != 472 || resp.Usage.CompletionTokens != 89 {
+		t.Errorf("usage = %+v, want 472 prompt and 89 completion tokens", resp.Usage)
+	}
+	if last := events[len(events)-1]; last != EventDone {
+		t.Errorf("last event = %s, want %s", last, EventDone)
+	}
+}
+
+func TestAnthropicStreamError(t *testing.T) {
+	a := newAnthropicServer(t, func(w http.ResponseWriter, req anthropicRequest) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n")
+	})
+
+	var failed bool
+	sink := func(event StreamEvent) {
+		failed = failed || event.Type == EventError
+	}
+	_, err := a.Send(context.Background(), Request{Message: TextContent("Hi")}, sink)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Type != "overloaded_error" {
+		t.Fatalf("err = %v, want an overloaded_error APIError", err)
+	}
+	if !failed {
+		t.Error("the sink did not receive an error event")
+	}
+}
+
+func TestAnthropicSingleResponse(t *testing.T) {
+	temperature := 0.5
+	a := newAnthropicServer(t, func(w http.ResponseWriter, req anthropicRequest) {
+		if req.Stream {
+			t.Error("stream was requested without a sink")
+		}
+		if req.Model != anthropicDefaultModel || req.MaxTokens != anthropicMaxTokens {
+			t.Errorf("model = %q, max_tokens = %d", req.Model, req.MaxTokens)
+		}
+		if req.Temperature == nil || *req.Temperature != temperature {
+			t.Errorf("temperature = %v, want %v", req.Temperature, temperature)
+		}
+		io.WriteString(w, `{"content":[{"type":"text","text":"Hello!"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":3}}`)
+	})
+
+	resp, err := a.Send(context.Background(), Request{
+		Message:  TextContent("Hi"),
+		Sampling: Sampling{Temperature: &temperature},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Hello!" || resp.Usage.PromptTokens != 10 || resp.Usage.CompletionTokens != 3 {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestBuildAnthropicMessages(t *testing.T) {
+	history := []Message{
+		{Role: "assistant", Content: TextContent("dropped, as the conversation must open with the user")},
+		{Role: "system", Content: TextContent("Be brief.")},
+		{Role: "user", Content: TextContent("first")},
+		{Role: "user", Content: TextContent("second")},
+		{Role: "assistant", Content: Content{
+			{Type: PartText, Text: "Checking."},
+			ToolCallPart(ToolCall{ID: "call_1", Name: "read_file", Arguments: `{"path":"go.mod"}`}),
+		}},
+		{Role: "tool", Content: Content{ToolResultPart("call_1", "module gopilot")}},
+		{Role: "system", Content: TextContent("Answer in English.")},
+	}
+
+	system, messages := buildAnthropicMessages(history, TextContent("and now?"))
+
+	if want := "Be brief.\n\nAnswer in English."; system != want {
+		t.Errorf("system = %q, want %q", system, want)
+	}
+	var roles []string
+	for _, msg := range messages {
+		roles = append(roles, msg.Role)
+	}
+	if got := strings.Join(roles, ","); got != "user,assistant,user" {
+		t.Fatalf("roles = %s, want user,assistant,user", got)
+	}
+	if n := len(messages[0].Content); n != 2 {
+		t.Errorf("consecutive user turns were not merged: %d blocks", n)
+	}
+	toolUse := messages[1].Content[1]
+	if toolUse.Type != "tool_use" || toolUse.ID != "call_1" || string(toolUse.Input) != `{"path":"go.mod"}` {
+		t.Errorf("tool_use block = %+v", toolUse)
+	}
+	result := messages[2].Content
+	if len(result) != 2 || result[0].Type != "tool_result" || result[0].ToolUseID != "call_1" || result[1].Text != "and now?" {
+		t.Errorf("last user turn = %+v", result)
+	}
+}
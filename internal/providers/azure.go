@@ -0,0 +1,54 @@
+package providers
+
+import (
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const azureDefaultAPIVersion = "2024-02-01"
+
+// AzureOpenAI routes requests to a deployment on an Azure OpenAI resource,
+// authenticating with the api-key header.
+type AzureOpenAI struct {
+	openAIChat
+}
+
+func init() {
+	Register("azure-openai", []string{"azure"}, func(opts Options) (Provider, error) {
+		return NewAzureOpenAI(opts)
+	})
+}
+
+func NewAzureOpenAI(opts Options) (*AzureOpenAI, error) {
+	if opts.BaseURL == "" {
+		return nil, fmt.Errorf("azure-openai provider requires BASE_URL to be set to the resource endpoint")
+	}
+	if opts.Deployment == "" {
+		return nil, fmt.Errorf("azure-openai provider requires DEPLOYMENT to be set")
+	}
+
+	config := openai.DefaultAzureConfig(opts.APIKey, opts.BaseURL)
+	if opts.APIVersion != "" {
+		config.APIVersion = opts.APIVersion
+	} else {
+		config.APIVersion = azureDefaultAPIVersion
+	}
+
+	// Azure addresses models by deployment, so every model name maps to it
+	deployment := opts.Deployment
+	config.AzureModelMapperFunc = func(string) string {
+		return deployment
+	}
+
+	if opts.Model == "" {
+		opts.Model = deployment
+	}
+
+	// The client already sends api-version on every Azure request
+	opts.APIVersion = ""
+
+	return &AzureOpenAI{
+		openAIChat: newOpenAIChatWithConfig("azure-openai", config, opts),
+	}, nil
+}
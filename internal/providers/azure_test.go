@@ -0,0 +1,86 @@
+package providers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const azureCompletion = `{"id":"chatcmpl-1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"Hi there"},"finish_reason":"stop"}],"usage":{"prompt_tokens":9,"completion_tokens":2,"total_tokens":11}}`
+
+func TestAzureOpenAIRoutesToDeployment(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiVersion string
+		wantQuery  string
+	}{
+		{"default API version", "", "api-version=" + azureDefaultAPIVersion},
+		{"configured API version", "2024-06-01", "api-version=2024-06-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if want := "/openai/deployments/prod-gpt4o/chat/completions"; r.URL.Path != want {
+					t.Errorf("path = %q, want %q", r.URL.Path, want)
+				}
+				if r.URL.RawQuery != tt.wantQuery {
+					t.Errorf("query = %q, want %q", r.URL.RawQuery, tt.wantQuery)
+				}
+				if got := r.Header.Get("api-key"); got != "azure-key" {
+					t.Errorf("api-key = %q, want azure-key", got)
+				}
+				if got := r.Header.Get("Authorization"); got != "" {
+					t.Errorf("Authorization = %q, want none", got)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, azureCompletion)
+			}))
+			defer server.Close()
+
+			azure, err := NewAzureOpenAI(Options{
+				APIKey:     "azure-key",
+				BaseURL:    server.URL,
+				Deployment: "prod-gpt4o",
+				Model:      "gpt-4o",
+				APIVersion: tt.apiVersion,
+				Retry:      RetryPolicy{MaxAttempts: 1},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := azure.Send(context.Background(), Request{Message: TextContent("Hi")}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Content != "Hi there" || resp.Usage.PromptTokens != 9 || resp.Usage.CompletionTokens != 2 {
+				t.Errorf("response = %+v", resp)
+			}
+		})
+	}
+}
+
+func TestAzureOpenAIModelDefaultsToDeployment(t *testing.T) {
+	azure, err := NewAzureOpenAI(Options{BaseURL: "https://example.openai.azure.com", Deployment: "prod-gpt4o"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := azure.model; got != "prod-gpt4o" {
+		t.Errorf("model = %q, want the deployment", got)
+	}
+}
+
+func TestAzureOpenAIRequiresEndpointAndDeployment(t *testing.T) {
+	tests := map[string]Options{
+		"BASE_URL":   {Deployment: "prod-gpt4o"},
+		"DEPLOYMENT": {BaseURL: "https://example.openai.azure.com"},
+	}
+	for setting, opts := range tests {
+		_, err := NewAzureOpenAI(opts)
+		if err == nil || !strings.Contains(err.Error(), setting) {
+			t.Errorf("err = %v, want one naming %s", err, setting)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package providers
+
+import (
+	"sort"
+	"strings"
+)
+
+// ModelInfo describes a model's limits, pricing and capabilities. Prices are
+// in USD per million tokens.
+type ModelInfo struct {
+	Name            string  `json:"NAME" yaml:"NAME"`
+	ContextWindow   int     `json:"CONTEXT_WINDOW" yaml:"CONTEXT_WINDOW"`
+	MaxOutputTokens int     `json:"MAX_OUTPUT_TOKENS" yaml:"MAX_OUTPUT_TOKENS"`
+	InputPrice      float64 `json:"INPUT_PRICE" yaml:"INPUT_PRICE"`
+	OutputPrice     float64 `json:"OUTPUT_PRICE" yaml:"OUTPUT_PRICE"`
+	Streaming       bool    `json:"STREAMING" yaml:"STREAMING"`
+	Tools           bool    `json:"TOOLS" yaml:"TOOLS"`
+	Vision          bool    `json:"VISION" yaml:"VISION"`
+}
+
+// catalog holds the known models for each provider, keyed by provider then model name
+var catalog = map[string]map[string]ModelInfo{
+	"openai": modelSet(
+		ModelInfo{Name: "gpt-4.1", ContextWindow: 1047576, MaxOutputTokens: 32768, InputPrice: 2.00, OutputPrice: 8.00, Streaming: true, Tools: true, Vision: true},
+		ModelInfo{Name: "gpt-4.1-mini", ContextWindow: 1047576, MaxOutputTokens: 32768, InputPrice: 0.40, OutputPrice: 1.60, Streaming: true, Tools: true, Vision: true},
+		ModelInfo{Name: "gpt-4o", ContextWindow: 128000, MaxOutputTokens: 16384, InputPrice: 2.50, OutputPrice: 10.00, Streaming: true, Tools: true, Vision: true},
+		ModelInfo{Name: "gpt-4o-mini", ContextWindow: 128000, MaxOutputTokens: 16384, InputPrice: 0.15, OutputPrice: 0.60, Streaming: true, Tools: true, Vision: true},
+		ModelInfo{Name: "gpt-4-turbo", ContextWindow: 128000, MaxOutputTokens: 4096, InputPrice: 10.00, OutputPrice: 30.00, Streaming: true, Tools: true, Vision: true},
+		ModelInfo{Name: "gpt-4-turbo-preview", ContextWindow: 128000, MaxOutputTokens: 4096, InputPrice: 10.00, OutputPrice: 30.00, Streaming: true, Tools: true},
+		ModelInfo{Name: "gpt-4", ContextWindow: 8192, MaxOutputTokens: 8192, InputPrice: 30.00, OutputPrice: 60.00, Streaming: true, Tools: true},
+		ModelInfo{Name: "gpt-3.5-turbo", ContextWindow: 16385, MaxOutputTokens: 4096, InputPrice: 0.50, OutputPrice: 1.50, Streaming: true, Tools: true},
+		ModelInfo{Name: "gpt-3.5-turbo-0125", ContextWindow: 16385, MaxOutputTokens: 4096, InputPrice: 0.50, OutputPrice: 1.50, Streaming: true, Tools: true},
+		ModelInfo{Name: "o1", ContextWindow: 200000, MaxOutputTokens: 100000, InputPrice: 15.00, OutputPrice: 60.00, Streaming: true, Tools: true, Vision: true},
+		ModelInfo{Name: "o1-mini", ContextWindow: 128000, MaxOutputTokens: 65536, InputPrice: 1.10, OutputPrice: 4.40},
+		ModelInfo{Name: "o3-mini", ContextWindow: 200000, MaxOutputTokens: 100000, InputPrice: 1.10, OutputPrice: 4.40, Streaming: true, Tools: true},
+	),
+	"anthropic": modelSet(
+		ModelInfo{Name: "claude-opus-4-20250514", ContextWindow: 200000, MaxOutputTokens: 32000, InputPrice: 15.00, OutputPrice: 75.00, Streaming: true, Tools: true, Vision: true},
+		ModelInfo{Name: "claude-sonnet-4-20250514", ContextWindow: 200000, MaxOutputTokens: 64000, InputPrice: 3.00, OutputPrice: 15.00, Streaming: true, Tools: true, Vision: true},
+		ModelInfo{Name: "claude-3-5-sonnet-latest", ContextWindow: 200000, MaxOutputTokens: 8192, InputPrice: 3.00, OutputPrice: 15.00, Streaming: true, Tools: true, Vision: true},
+		ModelInfo{Name: "claude-3-5-haiku-latest", ContextWindow: 200000, MaxOutputTokens: 8192, InputPrice: 0.80, OutputPrice: 4.00, Streaming: true, Tools: true},
+		ModelInfo{Name: "claude-3-opus-latest", ContextWindow: 200000, MaxOutputTokens: 4096, InputPrice: 15.00, OutputPrice: 75.00, Streaming: true, Tools: true, Vision: true},
+		ModelInfo{Name: "claude-3-haiku-20240307", ContextWindow: 200000, MaxOutputTokens: 4096, InputPrice: 0.25, OutputPrice: 1.25, Streaming: true, Tools: true, Vision: true},
+	),
+	"cohere-ai": modelSet(
+		ModelInfo{Name: "command-r", ContextWindow: 128000, MaxOutputTokens: 4000, InputPrice: 0.15, OutputPrice: 0.60, Streaming: true},
+		ModelInfo{Name: "command-r-plus", ContextWindow: 128000, MaxOutputTokens: 4000, InputPrice: 2.50, OutputPrice: 10.00, Streaming: true},
+		ModelInfo{Name: "command", ContextWindow: 4096, MaxOutputTokens: 4000, InputPrice: 1.00, OutputPrice: 2.00, Streaming: true},
+	),
+	"gemini": modelSet(
+		ModelInfo{Name: "gemini-2.0-flash", ContextWindow: 1048576, MaxOutputTokens: 8192, InputPrice: 0.10, OutputPrice: 0.40, Streaming: true, Vision: true},
+		ModelInfo{Name: "gemini-1.5-flash", ContextWindow: 1048576, MaxOutputTokens: 8192, InputPrice: 0.075, OutputPrice: 0.30, Streaming: true, Vision: true},
+		ModelInfo{Name: "gemini-1.5-pro", ContextWindow: 2097152, MaxOutputTokens: 8192, InputPrice: 1.25, OutputPrice: 5.00, Streaming: true, Vision: true},
+	),
+	"openrouter": modelSet(
+		ModelInfo{Name: "openai/gpt-3.5-turbo", ContextWindow: 16385, MaxOutputTokens: 4096, InputPrice: 0.50, OutputPrice: 1.50, Streaming: true, Tools: true},
+		ModelInfo{Name: "openai/gpt-4o", ContextWindow: 128000, MaxOutputTokens: 16384, InputPrice: 2.50, OutputPrice: 10.00, Streaming: true, Tools: true, Vision: true},
+		ModelInfo{Name: "openai/gpt-4o-mini", ContextWindow: 128000, MaxOutputTokens: 16384, InputPrice: 0.15, OutputPrice: 0.60, Streaming: true, Tools: true, Vision: true},
+		ModelInfo{Name: "anthropic/claude-3.5-sonnet", ContextWindow: 200000, MaxOutputTokens: 8192, InputPrice: 3.00, OutputPrice: 15.00, Streaming: true, Tools: true, Vision: true},
+		ModelInfo{Name: "google/gemini-flash-1.5", ContextWindow: 1000000, MaxOutputTokens: 8192, InputPrice: 0.075, OutputPrice: 0.30, Streaming: true, Tools: true, Vision: true},
+	),
+	"huggingface": modelSet(
+		ModelInfo{Name: "HuggingFaceH4/zephyr-7b-beta", ContextWindow: 8192, MaxOutputTokens: 1024, Streaming: true},
+		ModelInfo{Name: "mistralai/Mistral-7B-Instruct-v0.3", ContextWindow: 32768, MaxOutputTokens: 1024, Streaming: true},
+		ModelInfo{Name: "meta-llama/Meta-Llama-3-8B-Instruct", ContextWindow: 8192, MaxOutputTokens: 1024, Streaming: true},
+	),
+	"ollama": modelSet(
+		ModelInfo{Name: "llama3", ContextWindow: 8192, MaxOutputTokens: 2048, Streaming: true},
+		ModelInfo{Name: "llama3.1", ContextWindow: 131072, MaxOutputTokens: 2048, Streaming: true},
+		ModelInfo{Name: "mistral", ContextWindow: 32768, MaxOutputTokens: 2048, Streaming: true},
+		ModelInfo{Name: "llava", ContextWindow: 4096, MaxOutputTokens: 2048, Streaming: true, Vision: true},
+	),
+}
+
+func modelSet(models ...ModelInfo) map[string]ModelInfo {
+	set := make(map[string]ModelInfo, len(models))
+	for _, model := range models {
+		set[model.Name] = model
+	}
+	return set
+}
+
+// LookupModel returns the catalog entry for a provider's model.
+func LookupModel(provider, model string) (ModelInfo, bool) {
+	info, ok := catalog[CanonicalName(provider)][model]
+	return info, ok
+}
+
+// Models returns the catalog entries for a provider sorted by name.
+func Models(provider string) []ModelInfo {
+	provider = CanonicalName(provider)
+	models := make([]ModelInfo, 0, len(catalog[provider]))
+	for _, info := range catalog[provider] {
+		models = append(models, info)
+	}
+	sort.Slice(models, func(i, j int) bool {
+		return models[i].Name < models[j].Name
+	})
+	return models
+}
+
+// CatalogProviders returns the providers that have catalog entries.
+func CatalogProviders() []string {
+	names := make([]string, 0, len(catalog))
+	for name := range catalog {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterModels adds or replaces catalog entries for a provider, e.g. from
+// the MODELS section of the config file. The map key is used as the name.
+func RegisterModels(provider string, models map[string]ModelInfo) {
+	if len(models) == 0 {
+		return
+	}
+	provider = CanonicalName(provider)
+	if catalog[provider] == nil {
+		catalog[provider] = make(map[string]ModelInfo)
+	}
+	for name, info := range models {
+		info.Name = name
+		catalog[provider][name] = info
+	}
+}
+
+// modelNames lists the names of a provider's catalog entries.
+func modelNames(provider string) string {
+	models := Models(provider)
+	names := make([]string, len(models))
+	for i, model := range models {
+		names[i] = model.Name
+	}
+	return strings.Join(names, ", ")
+}
@@ -1,32 +1,295 @@
 package providers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	cohereBaseURL      = "https://api.cohere.com/v1"
+	cohereDefaultModel = "command-r"
 )
 
 type Cohere struct {
-	apiKey string
-	model  string
+	api   httpAPI
+	model string
+}
+
+type cohereChatMessage struct {
+	Role    string `json:"role"`
+	Message string `json:"message"`
+}
+
+type cohereRequest struct {
+	Model       string              `json:"model"`
+	Message     string              `json:"message"`
+	Preamble    string              `json:"preamble,omitempty"`
+	ChatHistory []cohereChatMessage `json:"chat_history,omitempty"`
+	Stream      bool                `json:"stream,omitempty"`
+
+	Temperature   *float64 `json:"temperature,omitempty"`
+	P             *float64 `json:"p,omitempty"`
+	Seed          *int     `json:"seed,omitempty"`
+	MaxTokens     int      `json:"max_tokens,omitempty"`
+	StopSequences []string `json:"stop_sequences,omitempty"`
+
+	ResponseFormat *cohereResponseFormat `json:"response_format,omitempty"`
+}
+
+type cohereResponseFormat struct {
+	Type   string          `json:"type"`
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+type cohereResponse struct {
+	Text         string `json:"text"`
+	FinishReason string `json:"finish_reason"`
+	Meta         struct {
+		BilledUnits struct {
+			InputTokens  usageCount `json:"input_tokens"`
+			OutputTokens usageCount `json:"output_tokens"`
+		} `json:"billed_units"`
+	} `json:"meta"`
 }
 
-func NewCohere(apiKey, model string) (*Cohere, error) {
+func (r *cohereResponse) usage() Usage {
+	return Usage{
+		PromptTokens:     int(r.Meta.BilledUnits.InputTokens),
+		CompletionTokens: int(r.Meta.BilledUnits.OutputTokens),
+	}
+}
+
+type cohereStreamEvent struct {
+	EventType    string          `json:"event_type"`
+	Text         string          `json:"text"`
+	FinishReason string          `json:"finish_reason"`
+	Response     *cohereResponse `json:"response"`
+}
+
+func init() {
+	Register("cohere-ai", []string{"cohere"}, func(opts Options) (Provider, error) {
+		return NewCohere(opts)
+	})
+}
+
+func NewCohere(opts Options) (*Cohere, error) {
+	model := opts.Model
 	if model == "" {
-		model = "command"
+		model = cohereDefaultModel
+	}
+
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = cohereBaseURL
 	}
-	return &Cohere{
-		apiKey: apiKey,
-		model:  model,
-	}, nil
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+opts.APIKey)
+	header.Set("Accept", "application/json")
+
+	c := &Cohere{
+		api: httpAPI{
+			provider:    "cohere",
+			baseURL:     baseURL,
+			header:      header,
+			decodeError: decodeCohereError,
+		},
+		model: model,
+	}
+	c.api.client = newRetryClient(nil, opts.Retry, c.HandleRateLimiting)
+
+	return c, nil
 }
 
-func (c *Cohere) Send(history []Message, message interface{}, stream bool) (string, error) {
-	return "", fmt.Errorf("cohere provider not yet implemented - requires cohere-go client")
+func (c *Cohere) Send(ctx context.Context, request Request, sink StreamSink) (*Response, error) {
+	if err := toolsUnsupported("cohere", request); err != nil {
+		return nil, err
+	}
+	if err := imagesUnsupported("cohere", request); err != nil {
+		return nil, err
+	}
+
+	// Cohere takes the input apart from the history, so a request continuing
+	// the history sends its last user turn as the message
+	history, message := request.History, request.Message.Text()
+	if request.Message == nil {
+		if n := len(history); n > 0 && history[n-1].Role == "user" {
+			history, message = history[:n-1], history[n-1].Content.Text()
+		}
+	}
+	if message == "" {
+		return nil, fmt.Errorf("cohere: the request has no user message to send")
+	}
+
+	preamble, chatHistory := buildCohereHistory(history)
+
+	req := cohereRequest{
+		Model:         c.model,
+		Message:       message,
+		Preamble:      preamble,
+		ChatHistory:   chatHistory,
+		Stream:        sink != nil,
+		Temperature:   request.Sampling.Temperature,
+		P:             request.Sampling.TopP,
+		Seed:          request.Sampling.Seed,
+		MaxTokens:     request.Sampling.MaxTokens,
+		StopSequences: request.Sampling.Stop,
+	}
+	if request.ResponseFormat != nil {
+		req.ResponseFormat = &cohereResponseFormat{Type: "json_object", Schema: request.ResponseFormat.Schema}
+	}
+
+	resp, err := c.api.post(ctx, "/chat", req)
+	if err != nil {
+		return nil, c.HandleRateLimiting(err)
+	}
+
+	var response *Response
+	if sink != nil {
+		response, err = c.handleStreamingResponse(ctx, resp, sink)
+	} else {
+		response, err = c.handleSingleResponse(resp)
+	}
+	if err != nil {
+		return nil, c.HandleRateLimiting(err)
+	}
+	return response, nil
 }
 
 func (c *Cohere) SupportsStreaming() bool {
-	return false
+	return true
 }
 
+func (c *Cohere) Model() string {
+	return c.model
+}
+
+// HandleRateLimiting classifies errors for retries and translates Cohere's
+// status codes into actionable messages.
 func (c *Cohere) HandleRateLimiting(err error) error {
-	return err
+	classified := classifyError(err)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return classified
+	}
+
+	var translated error
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests:
+		translated = fmt.Errorf("cohere: rate limit exceeded, trial keys are heavily throttled: %w", err)
+	case http.StatusUnauthorized, 498:
+		translated = fmt.Errorf("cohere: invalid or expired API key: %w", err)
+	case 499:
+		translated = fmt.Errorf("cohere: request was cancelled: %w", err)
+	default:
+		return classified
+	}
+
+	if retryable, ok := classified.(*RetryableError); ok {
+		retryable.Err = translated
+		return retryable
+	}
+	return translated
+}
+
+func (c *Cohere) handleStreamingResponse(ctx context.Context, resp *http.Response, sink StreamSink) (*Response, error) {
+	defer resp.Body.Close()
+
+	// Cohere streams newline-delimited JSON events rather than SSE
+	decoder := json.NewDecoder(resp.Body)
+	var fullResponse strings.Builder
+	for {
+		var event cohereStreamEvent
+		err := decoder.Decode(&event)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, sink.fail(ctx, fmt.Errorf("cohere: invalid stream event: %w", err))
+		}
+
+		switch event.EventType {
+		case "text-generation":
+			sink.text(event.Text)
+			fullResponse.WriteString(event.Text)
+		case "stream-end":
+			if err := cohereFinishError(event.FinishReason); err != nil {
+				return nil, sink.fail(ctx, err)
+			}
+			var usage Usage
+			if event.Response != nil {
+				usage = event.Response.usage()
+			}
+			sink.usage(usage)
+			sink.done()
+			return &Response{Content: fullResponse.String(), Usage: usage}, nil
+		}
+	}
+	sink.done()
+	return &Response{Content: fullResponse.String()}, nil
+}
+
+func (c *Cohere) handleSingleResponse(resp *http.Response) (*Response, error) {
+	var result cohereResponse
+	if err := decodeJSON(resp, &result); err != nil {
+		return nil, fmt.Errorf("cohere: invalid response: %w", err)
+	}
+	if err := cohereFinishError(result.FinishReason); err != nil {
+		return nil, err
+	}
+	return &Response{Content: result.Text, Usage: result.usage()}, nil
+}
+
+// buildCohereHistory maps history onto Cohere's preamble and chat_history.
+// System entries become the preamble, user turns USER and assistant turns CHATBOT.
+func buildCohereHistory(history []Message) (string, []cohereChatMessage) {
+	var preamble []string
+	var chatHistory []cohereChatMessage
+
+	for _, msg := range history {
+		content := msg.Content.Text()
+		if content == "" {
+			continue
+		}
+
+		switch msg.Role {
+		case "system":
+			preamble = append(preamble, content)
+		case "assistant":
+			chatHistory = append(chatHistory, cohereChatMessage{Role: "CHATBOT", Message: content})
+		default:
+			chatHistory = append(chatHistory, cohereChatMessage{Role: "USER", Message: content})
+		}
+	}
+
+	return strings.Join(preamble, "\n\n"), chatHistory
+}
+
+// cohereFinishError reports generations Cohere ended abnormally.
+func cohereFinishError(reason string) error {
+	switch reason {
+	case "ERROR":
+		return fmt.Errorf("cohere: generation failed")
+	case "ERROR_TOXIC":
+		return fmt.Errorf("cohere: generation stopped by content filter")
+	case "ERROR_LIMIT":
+		return fmt.Errorf("cohere: generation exceeded the model's context limit")
+	}
+	return nil
+}
+
+func decodeCohereError(body []byte) (string, string) {
+	var payload struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", ""
+	}
+	return "", payload.Message
 }
@@ -0,0 +1,149 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newCohereServer serves the Chat API from handler
+func newCohereServer(t *testing.T, handler func(w http.ResponseWriter, req cohereRequest)) *Cohere {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Authorization = %q, want the bearer key", got)
+		}
+		var req cohereRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		handler(w, req)
+	}))
+	t.Cleanup(server.Close)
+
+	c, err := NewCohere(Options{APIKey: "test-key", BaseURL: server.URL, Retry: RetryPolicy{MaxAttempts: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCohereStreaming(t *testing.T) {
+	c := newCohereServer(t, func(w http.ResponseWriter, req cohereRequest) {
+		if !req.Stream || req.Message != "And in Paris?" || req.Preamble != "Be brief." {
+			t.Errorf("request = %+v", req)
+		}
+		want := []cohereChatMessage{{Role: "USER", Message: "Weather in Rome?"}, {Role: "CHATBOT", Message: "Sunny."}}
+		if len(req.ChatHistory) != len(want) || req.ChatHistory[0] != want[0] || req.ChatHistory[1] != want[1] {
+			t.Errorf("chat_history = %+v, want %+v", req.ChatHistory, want)
+		}
+		// Events are newline-delimited JSON, not SSE
+		io.WriteString(w, `{"event_type":"stream-start"}`+"\n")
+		io.WriteString(w, `{"event_type":"text-generation","text":"Rainy"}`+"\n")
+		io.WriteString(w, `{"event_type":"text-generation","text":" too."}`+"\n")
+		io.WriteString(w, `{"event_type":"stream-end","finish_reason":"COMPLETE","response":{"text":"Rainy too.","meta":{"billed_units":{"input_tokens":20,"output_tokens":3}}}}`+"\n")
+	})
+
+	var streamed strings.Builder
+	sink := func(event StreamEvent) {
+		if event.Type == EventTextDelta {
+			streamed.WriteString(event.Text)
+		}
+	}
+	resp, err := c.Send(context.Background(), Request{
+		History: []Message{
+			{Role: "system", Content: TextContent("Be brief.")},
+			{Role: "user", Content: TextContent("Weather in Rome?")},
+			{Role: "assistant", Content: TextContent("Sunny.")},
+		},
+		Message: TextContent("And in Paris?"),
+	}, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Rainy too." || streamed.String() != "Rainy too." {
+		t.Errorf("content = %q, streamed %q, want Rainy too.", resp.Content, streamed.String())
+	}
+	if resp.Usage.PromptTokens != 20 || resp.Usage.CompletionTokens != 3 {
+		t.Errorf("usage = %+v, want 20 prompt and 3 completion tokens", resp.Usage)
+	}
+}
+
+func TestCohereErrors(t *testing.T) {
+	for _, test := range []struct {
+		status int
+		body   string
+		want   string
+	}{
+		{http.StatusTooManyRequests, `{"message":"trial key limit"}`, "rate limit exceeded"},
+		{http.StatusUnauthorized, `{"message":"invalid api token"}`, "invalid or expired API key"},
+		{http.StatusOK, `{"text":"","finish_reason":"ERROR_TOXIC"}`, "content filter"},
+	} {
+		c := newCohereServer(t, func(w http.ResponseWriter, req cohereRequest) {
+			w.WriteHeader(test.status)
+			io.WriteString(w, test.body)
+		})
+		_, err := c.Send(context.Background(), Request{Message: TextContent("Hi")}, nil)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("status %d: got %v, want %q", test.status, err, test.want)
+		}
+	}
+}
+
+func TestCohereContinuesHistory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req cohereRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		// Without a new message the last user turn is sent as the message
+		if req.Message != "And in Paris?" {
+			t.Errorf("message = %q, want the last user turn", req.Message)
+		}
+		want := []cohereChatMessage{{Role: "USER", Message: "Weather in Rome?"}, {Role: "CHATBOT", Message: "Sunny."}}
+		if len(req.ChatHistory) != len(want) || req.ChatHistory[0] != want[0] || req.ChatHistory[1] != want[1] {
+			t.Errorf("chat_history = %+v, want %+v", req.ChatHistory, want)
+		}
+		if req.Preamble != "Be brief." {
+			t.Errorf("preamble = %q, want the system message", req.Preamble)
+		}
+		io.WriteString(w, `{"text":"Rainy.","finish_reason":"COMPLETE","meta":{"billed_units":{"input_tokens":20,"output_tokens":3}}}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewCohere(Options{APIKey: "test", BaseURL: server.URL, Retry: RetryPolicy{MaxAttempts: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := provider.Send(context.Background(), Request{
+		History: []Message{
+			{Role: "system", Content: TextContent("Be brief.")},
+			{Role: "user", Content: TextContent("Weather in Rome?")},
+			{Role: "assistant", Content: TextContent("Sunny.")},
+			{Role: "user", Content: TextContent("And in Paris?")},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Rainy." || resp.Usage.PromptTokens != 20 {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestCohereRequiresAMessage(t *testing.T) {
+	provider, err := NewCohere(Options{APIKey: "test", BaseURL: "http://127.0.0.1:0", Retry: RetryPolicy{MaxAttempts: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = provider.Send(context.Background(), Request{
+		History: []Message{{Role: "assistant", Content: TextContent("Hello.")}},
+	}, nil)
+	if err == nil {
+		t.Fatal("sending a request with no user message succeeded")
+	}
+}
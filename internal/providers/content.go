@@ -0,0 +1,240 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxImageSize is the largest image the vision APIs accept inline
+const maxImageSize = 20 << 20
+
+// imageTypes are the image formats accepted by every vision API
+var imageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// PartType identifies the kind of a content part
+type PartType string
+
+const (
+	PartText       PartType = "text"
+	PartJSON       PartType = "json"
+	PartImage      PartType = "image"
+	PartFile       PartType = "file"
+	PartToolCall   PartType = "tool_call"
+	PartToolResult PartType = "tool_result"
+)
+
+// Part is one piece of a message's content. Which fields are set depends on
+// the Type.
+type Part struct {
+	Type PartType `json:"type"`
+	// Text is the text of a text part, or the output of a tool_result part
+	Text string `json:"text,omitempty"`
+	// Value is the structured input of a json part
+	Value json.RawMessage `json:"value,omitempty"`
+	// Name, MIMEType and Data describe an image or file part. Data is
+	// base64-encoded in JSON.
+	Name     string `json:"name,omitempty"`
+	MIMEType string `json:"mime_type,omitempty"`
+	Data     []byte `json:"data,omitempty"`
+	// ToolCall is the call made by a tool_call part
+	ToolCall *ToolCall `json:"tool_call,omitempty"`
+	// ToolCallID links a tool_result part to the call it answers
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+// Content is the content of a message, as a list of parts in order
+type Content []Part
+
+// TextContent returns content holding a single text part, or nil for an
+// empty string
+func TextContent(text string) Content {
+	if text == "" {
+		return nil
+	}
+	return Content{{Type: PartText, Text: text}}
+}
+
+// ContentOf converts a message input into content. Strings become text,
+// Content and Part values are kept as they are, and anything else, such as
+// a decoded JSON object, becomes a json part.
+func ContentOf(value interface{}) Content {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case Content:
+		return v
+	case []Part:
+		return Content(v)
+	case Part:
+		return Content{v}
+	case string:
+		return TextContent(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return TextContent(fmt.Sprint(v))
+		}
+		return Content{{Type: PartJSON, Value: data}}
+	}
+}
+
+// ImagePart returns an image part holding data of the given MIME type
+func ImagePart(name, mimeType string, data []byte) Part {
+	return Part{Type: PartImage, Name: name, MIMEType: mimeType, Data: data}
+}
+
+// ReadImage reads an image file into an image part, detecting its MIME type
+// from the contents
+func ReadImage(path string) (Part, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Part{}, err
+	}
+	if len(data) > maxImageSize {
+		return Part{}, fmt.Errorf("image %s is larger than %d MB", path, maxImageSize>>20)
+	}
+	mimeType := http.DetectContentType(data)
+	if !imageTypes[mimeType] {
+		return Part{}, fmt.Errorf("image %s has unsupported type %s; use PNG, JPEG, GIF or WebP", path, mimeType)
+	}
+	return ImagePart(filepath.Base(path), mimeType, data), nil
+}
+
+// FilePart returns a file part holding data of the given MIME type
+func FilePart(name, mimeType string, data []byte) Part {
+	return Part{Type: PartFile, Name: name, MIMEType: mimeType, Data: data}
+}
+
+// ToolCallPart returns a part recording a call the model made
+func ToolCallPart(call ToolCall) Part {
+	return Part{Type: PartToolCall, ToolCall: &call}
+}
+
+// ToolResultPart returns a part answering the call with the given ID
+func ToolResultPart(callID, output string) Part {
+	return Part{Type: PartToolResult, ToolCallID: callID, Text: output}
+}
+
+// Text flattens the content into plain text for APIs that only take text.
+// Text, json, text file and tool result parts are joined by blank lines;
+// images, binary files and tool calls are left out.
+func (c Content) Text() string {
+	var texts []string
+	for _, part := range c {
+		if text, ok := part.text(); ok && text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// text returns the part as plain text, and false if it has no text form
+func (p Part) text() (string, bool) {
+	switch p.Type {
+	case PartText, PartToolResult:
+		return p.Text, true
+	case PartJSON:
+		return string(p.Value), true
+	case PartFile:
+		if !p.IsTextFile() {
+			return "", false
+		}
+		if p.Name == "" {
+			return string(p.Data), true
+		}
+		return fmt.Sprintf("File %s:\n%s", p.Name, p.Data), true
+	default:
+		return "", false
+	}
+}
+
+// IsTextFile reports whether a file part holds text that can be inlined
+func (p Part) IsTextFile() bool {
+	if p.Type != PartFile {
+		return false
+	}
+	if strings.HasPrefix(p.MIMEType, "image/") || p.MIMEType == "application/pdf" {
+		return false
+	}
+	return utf8.Valid(p.Data)
+}
+
+// ToolCalls returns the calls held by the content's tool_call parts
+func (c Content) ToolCalls() []ToolCall {
+	var calls []ToolCall
+	for _, part := range c {
+		if part.Type == PartToolCall && part.ToolCall != nil {
+			calls = append(calls, *part.ToolCall)
+		}
+	}
+	return calls
+}
+
+// Parts returns the parts of the given type
+func (c Content) Parts(partType PartType) []Part {
+	var parts []Part
+	for _, part := range c {
+		if part.Type == partType {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// UnmarshalJSON decodes a list of parts. Plain strings and other JSON values,
+// as stored in histories written before content had parts, decode into a
+// text or json part.
+func (c *Content) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		*c = nil
+		return nil
+	case len(data) > 0 && data[0] == '"':
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		*c = TextContent(text)
+		return nil
+	case len(data) > 0 && data[0] == '[':
+		var parts []Part
+		if err := json.Unmarshal(data, &parts); err == nil && validParts(parts) {
+			*c = parts
+			return nil
+		}
+	}
+	*c = Content{{Type: PartJSON, Value: append(json.RawMessage(nil), data...)}}
+	return nil
+}
+
+// imagesUnsupported is returned by providers that only take text when the
+// new message carries images, rather than dropping them silently
+func imagesUnsupported(provider string, request Request) error {
+	if len(request.Message.Parts(PartImage)) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s: image inputs are not supported by this provider", provider)
+}
+
+// validParts reports whether every part has a type, telling a list of parts
+// apart from an arbitrary JSON array
+func validParts(parts []Part) bool {
+	for _, part := range parts {
+		if part.Type == "" {
+			return false
+		}
+	}
+	return true
+}
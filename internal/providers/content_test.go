@@ -0,0 +1,102 @@
+package providers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContentUnmarshalJSON(t *testing.T) {
+	for _, test := range []struct {
+		name, data string
+		want       Content
+	}{
+		{"null", `null`, nil},
+		{"legacy string", `"Hello"`, Content{{Type: PartText, Text: "Hello"}}},
+		{"legacy empty string", `""`, nil},
+		{"legacy object", `{"task": "review"}`, Content{{Type: PartJSON, Value: json.RawMessage(`{"task": "review"}`)}}},
+		{"legacy array", `[1, 2]`, Content{{Type: PartJSON, Value: json.RawMessage(`[1, 2]`)}}},
+		{"legacy array of objects", `[{"name": "a"}]`, Content{{Type: PartJSON, Value: json.RawMessage(`[{"name": "a"}]`)}}},
+		{"parts", `[{"type": "text", "text": "Hi"}, {"type": "tool_result", "tool_call_id": "1", "text": "ok"}]`, Content{
+			{Type: PartText, Text: "Hi"},
+			{Type: PartToolResult, ToolCallID: "1", Text: "ok"},
+		}},
+	} {
+		var got Content
+		if err := json.Unmarshal([]byte(test.data), &got); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(test.want)
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("%s: decoded %s, want %s", test.name, gotJSON, wantJSON)
+		}
+	}
+}
+
+func TestContentRoundTrip(t *testing.T) {
+	content := Content{
+		{Type: PartText, Text: "Describe"},
+		{Type: PartJSON, Value: json.RawMessage(`{"a":1}`)},
+		ImagePart("chart.png", "image/png", []byte{0x89, 'P', 'N', 'G'}),
+		ToolCallPart(ToolCall{ID: "1", Name: "read_file", Arguments: `{"path":"go.mod"}`}),
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Content
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	again, _ := json.Marshal(decoded)
+	if string(again) != string(data) {
+		t.Errorf("round trip gave %s, want %s", again, data)
+	}
+}
+
+func TestContentText(t *testing.T) {
+	content := Content{
+		{Type: PartText, Text: "Review"},
+		FilePart("main.go", "text/plain", []byte("package main")),
+		FilePart("logo.png", "image/png", []byte{0x89}),
+		{Type: PartJSON, Value: json.RawMessage(`{"a":1}`)},
+		ToolCallPart(ToolCall{ID: "1", Name: "f"}),
+	}
+	want := "Review\n\nFile main.go:\npackage main\n\n{\"a\":1}"
+	if got := content.Text(); got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+}
+
+func TestReadImage(t *testing.T) {
+	dir := t.TempDir()
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 16)...)
+	files := map[string][]byte{
+		"chart.png": png,
+		"notes.txt": []byte("not an image"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	part, err := ReadImage(filepath.Join(dir, "chart.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if part.Type != PartImage || part.Name != "chart.png" || part.MIMEType != "image/png" || len(part.Data) != len(png) {
+		t.Errorf("part = %+v, want a PNG image part", part)
+	}
+
+	if _, err := ReadImage(filepath.Join(dir, "notes.txt")); err == nil || !strings.Contains(err.Error(), "unsupported type") {
+		t.Errorf("reading a text file: got %v, want an unsupported type error", err)
+	}
+	if _, err := ReadImage(filepath.Join(dir, "missing.png")); err == nil {
+		t.Error("reading a missing file succeeded")
+	}
+}
@@ -0,0 +1,332 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	geminiBaseURL      = "https://generativelanguage.googleapis.com/v1beta"
+	geminiDefaultModel = "gemini-1.5-flash"
+)
+
+// Gemini talks to Google's Generative Language REST API.
+type Gemini struct {
+	api   httpAPI
+	model string
+}
+
+type geminiPart struct {
+	Text string `json:"text,omitempty"`
+	// InlineData carries an image or document
+	InlineData *geminiBlob `json:"inlineData,omitempty"`
+}
+
+type geminiBlob struct {
+	MIMEType string `json:"mimeType"`
+	Data     []byte `json:"data"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	Seed            *int     `json:"seed,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+	// ResponseMimeType is application/json for JSON mode
+	ResponseMimeType string `json:"responseMimeType,omitempty"`
+}
+
+type geminiRequest struct {
+	Contents          []geminiContent         `json:"contents"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+func init() {
+	Register("gemini", []string{"google"}, func(opts Options) (Provider, error) {
+		return NewGemini(opts)
+	})
+}
+
+func NewGemini(opts Options) (*Gemini, error) {
+	model := opts.Model
+	if model == "" {
+		model = geminiDefaultModel
+	}
+
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = geminiBaseURL
+	}
+
+	header := http.Header{}
+	header.Set("x-goog-api-key", opts.APIKey)
+
+	g := &Gemini{
+		api: httpAPI{
+			provider:    "gemini",
+			baseURL:     baseURL,
+			header:      header,
+			decodeError: decodeGeminiError,
+		},
+		model: model,
+	}
+	g.api.client = newRetryClient(nil, opts.Retry, g.HandleRateLimiting)
+
+	return g, nil
+}
+
+func (g *Gemini) Send(ctx context.Context, request Request, sink StreamSink) (*Response, error) {
+	if err := toolsUnsupported("gemini", request); err != nil {
+		return nil, err
+	}
+
+	req := buildGeminiRequest(request.History, request.Message)
+	if sampling := request.Sampling; !sampling.IsZero() || request.ResponseFormat != nil {
+		req.GenerationConfig = &geminiGenerationConfig{
+			Temperature:     sampling.Temperature,
+			TopP:            sampling.TopP,
+			Seed:            sampling.Seed,
+			MaxOutputTokens: sampling.MaxTokens,
+			StopSequences:   sampling.Stop,
+		}
+		// Gemini's responseSchema only accepts an OpenAPI subset, so the
+		// schema itself is left to the prompt
+		if request.ResponseFormat != nil {
+			req.GenerationConfig.ResponseMimeType = "application/json"
+		}
+	}
+
+	path := "/models/" + url.PathEscape(g.model) + ":generateContent"
+	if sink != nil {
+		path = "/models/" + url.PathEscape(g.model) + ":streamGenerateContent?alt=sse"
+	}
+
+	resp, err := g.api.post(ctx, path, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if sink != nil {
+		return g.handleStreamingResponse(ctx, resp, sink)
+	}
+	return g.handleSingleResponse(resp)
+}
+
+func (g *Gemini) SupportsStreaming() bool {
+	return true
+}
+
+func (g *Gemini) Model() string {
+	return g.model
+}
+
+func (g *Gemini) HandleRateLimiting(err error) error {
+	return classifyError(err)
+}
+
+// ListModels returns the models that support generateContent.
+func (g *Gemini) ListModels(ctx context.Context) ([]string, error) {
+	resp, err := g.api.get(ctx, "/models")
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Models []struct {
+			Name                       string   `json:"name"`
+			SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+		} `json:"models"`
+	}
+	if err := decodeJSON(resp, &result); err != nil {
+		return nil, fmt.Errorf("gemini: invalid model list: %w", err)
+	}
+
+	var models []string
+	for _, model := range result.Models {
+		for _, method := range model.SupportedGenerationMethods {
+			if method == "generateContent" {
+				models = append(models, strings.TrimPrefix(model.Name, "models/"))
+				break
+			}
+		}
+	}
+	return models, nil
+}
+
+func (g *Gemini) handleStreamingResponse(ctx context.Context, resp *http.Response, sink StreamSink) (*Response, error) {
+	defer resp.Body.Close()
+
+	reader := newSSEReader(resp.Body)
+	var fullResponse strings.Builder
+	var usage Usage
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, sink.fail(ctx, err)
+		}
+		if event.Data == "" {
+			continue
+		}
+
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
+			return nil, sink.fail(ctx, fmt.Errorf("gemini: invalid stream event: %w", err))
+		}
+
+		text, err := chunk.text()
+		if err != nil {
+			return nil, sink.fail(ctx, err)
+		}
+		sink.text(text)
+		fullResponse.WriteString(text)
+		// Every chunk carries the running totals, so the last one wins
+		if chunk.UsageMetadata.PromptTokenCount > 0 {
+			usage = chunk.usage()
+		}
+	}
+	sink.usage(usage)
+	sink.done()
+	return &Response{Content: fullResponse.String(), Usage: usage}, nil
+}
+
+func (g *Gemini) handleSingleResponse(resp *http.Response) (*Response, error) {
+	var result geminiResponse
+	if err := decodeJSON(resp, &result); err != nil {
+		return nil, fmt.Errorf("gemini: invalid response: %w", err)
+	}
+	text, err := result.text()
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Content: text, Usage: result.usage()}, nil
+}
+
+func (r *geminiResponse) usage() Usage {
+	return Usage{
+		PromptTokens:     r.UsageMetadata.PromptTokenCount,
+		CompletionTokens: r.UsageMetadata.CandidatesTokenCount,
+	}
+}
+
+// text returns the first candidate's text, or an error if the prompt or
+// the answer was blocked.
+func (r *geminiResponse) text() (string, error) {
+	if r.PromptFeedback.BlockReason != "" {
+		return "", fmt.Errorf("gemini: prompt blocked: %s", r.PromptFeedback.BlockReason)
+	}
+	if len(r.Candidates) == 0 {
+		return "", nil
+	}
+
+	candidate := r.Candidates[0]
+	if candidate.FinishReason == "SAFETY" || candidate.FinishReason == "RECITATION" {
+		return "", fmt.Errorf("gemini: response blocked: %s", candidate.FinishReason)
+	}
+
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		text.WriteString(part.Text)
+	}
+	return text.String(), nil
+}
+
+// buildGeminiRequest maps history onto Gemini's contents. System entries
+// become the systemInstruction, assistant turns use the "model" role and
+// consecutive turns from the same role are merged.
+func buildGeminiRequest(history []Message, message Content) geminiRequest {
+	var system []geminiPart
+	var contents []geminiContent
+
+	appendTurn := func(role string, parts []geminiPart) {
+		if len(parts) == 0 {
+			return
+		}
+		if len(contents) > 0 && contents[len(contents)-1].Role == role {
+			last := &contents[len(contents)-1]
+			last.Parts = append(last.Parts, parts...)
+			return
+		}
+		contents = append(contents, geminiContent{Role: role, Parts: parts})
+	}
+
+	for _, msg := range history {
+		switch msg.Role {
+		case "system":
+			if content := msg.Content.Text(); content != "" {
+				system = append(system, geminiPart{Text: content})
+			}
+		case "assistant":
+			// The conversation must open with a user turn
+			if len(contents) == 0 {
+				continue
+			}
+			appendTurn("model", geminiParts(msg.Content))
+		default:
+			appendTurn("user", geminiParts(msg.Content))
+		}
+	}
+	appendTurn("user", geminiParts(message))
+
+	req := geminiRequest{Contents: contents}
+	if len(system) > 0 {
+		req.SystemInstruction = &geminiContent{Parts: system}
+	}
+	return req
+}
+
+// geminiParts maps content onto Gemini parts, sending images and PDFs inline
+func geminiParts(content Content) []geminiPart {
+	var parts []geminiPart
+	for _, part := range content {
+		if part.Type == PartImage || (part.Type == PartFile && part.MIMEType == "application/pdf") {
+			parts = append(parts, geminiPart{InlineData: &geminiBlob{MIMEType: part.MIMEType, Data: part.Data}})
+			continue
+		}
+		if text, ok := part.text(); ok && text != "" {
+			parts = append(parts, geminiPart{Text: text})
+		}
+	}
+	return parts
+}
+
+func decodeGeminiError(body []byte) (string, string) {
+	var payload struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", ""
+	}
+	return payload.Error.Status, payload.Error.Message
+}
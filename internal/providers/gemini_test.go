@@ -0,0 +1,162 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newGeminiServer serves the Generative Language API from handler, checking
+// the key every request must carry
+func newGeminiServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, req geminiRequest)) *Gemini {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("x-goog-api-key"); got != "test-key" {
+			t.Errorf("x-goog-api-key = %q, want test-key", got)
+		}
+		var req geminiRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		handler(w, r, req)
+	}))
+	t.Cleanup(server.Close)
+
+	g, err := NewGemini(Options{APIKey: "test-key", BaseURL: server.URL, Retry: RetryPolicy{MaxAttempts: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestGeminiSingleResponse(t *testing.T) {
+	temperature := 0.0
+	g := newGeminiServer(t, func(w http.ResponseWriter, r *http.Request, req geminiRequest) {
+		if r.URL.Path != "/models/gemini-1.5-flash:generateContent" {
+			t.Errorf("path = %q, want the default model's generateContent", r.URL.Path)
+		}
+		if req.SystemInstruction == nil || req.SystemInstruction.Parts[0].Text != "Be brief." {
+			t.Errorf("systemInstruction = %+v, want the system message", req.SystemInstruction)
+		}
+		config := req.GenerationConfig
+		if config == nil || config.Temperature == nil || *config.Temperature != 0 || config.ResponseMimeType != "application/json" {
+			t.Errorf("generationConfig = %+v, want temperature 0 in JSON mode", config)
+		}
+		io.WriteString(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"{\"ok\":"},{"text":"true}"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":9,"candidatesTokenCount":4}}`)
+	})
+
+	resp, err := g.Send(context.Background(), Request{
+		History:        []Message{{Role: "system", Content: TextContent("Be brief.")}},
+		Message:        TextContent("Is it ok?"),
+		Sampling:       Sampling{Temperature: &temperature},
+		ResponseFormat: &ResponseFormat{},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != `{"ok":true}` || resp.Usage.PromptTokens != 9 || resp.Usage.CompletionTokens != 4 {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestGeminiStreaming(t *testing.T) {
+	g := newGeminiServer(t, func(w http.ResponseWriter, r *http.Request, req geminiRequest) {
+		if r.URL.Path != "/models/gemini-1.5-flash:streamGenerateContent" || r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("url = %q, want streamGenerateContent as SSE", r.URL)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"Hello\"}]}}],\"usageMetadata\":{\"promptTokenCount\":5,\"candidatesTokenCount\":1}}\n\n")
+		io.WriteString(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\" there\"}]},\"finishReason\":\"STOP\"}],\"usageMetadata\":{\"promptTokenCount\":5,\"candidatesTokenCount\":2}}\n\n")
+	})
+
+	var text strings.Builder
+	var events []EventType
+	sink := func(event StreamEvent) {
+		events = append(events, event.Type)
+		if event.Type == EventTextDelta {
+			text.WriteString(event.Text)
+		}
+	}
+	resp, err := g.Send(context.Background(), Request{Message: TextContent("Hi")}, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Hello there" || text.String() != "Hello there" {
+		t.Errorf("content = %q, streamed %q, want Hello there", resp.Content, text.String())
+	}
+	// The last chunk's running totals are the usage
+	if resp.Usage.PromptTokens != 5 || resp.Usage.CompletionTokens != 2 {
+		t.Errorf("usage = %+v, want 5 prompt and 2 completion tokens", resp.Usage)
+	}
+	want := []EventType{EventTextDelta, EventTextDelta, EventUsage, EventDone}
+	if len(events) != len(want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("events = %v, want %v", events, want)
+			break
+		}
+	}
+}
+
+func TestGeminiBlockedResponses(t *testing.T) {
+	for name, body := range map[string]string{
+		"prompt blocked: SAFETY":       `{"promptFeedback":{"blockReason":"SAFETY"}}`,
+		"response blocked: RECITATION": `{"candidates":[{"content":{"parts":[]},"finishReason":"RECITATION"}]}`,
+	} {
+		g := newGeminiServer(t, func(w http.ResponseWriter, r *http.Request, req geminiRequest) {
+			io.WriteString(w, body)
+		})
+		_, err := g.Send(context.Background(), Request{Message: TextContent("Hi")}, nil)
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("got %v, want %q", err, name)
+		}
+	}
+}
+
+func TestGeminiError(t *testing.T) {
+	g := newGeminiServer(t, func(w http.ResponseWriter, r *http.Request, req geminiRequest) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error":{"code":400,"message":"API key not valid.","status":"INVALID_ARGUMENT"}}`)
+	})
+	_, err := g.Send(context.Background(), Request{Message: TextContent("Hi")}, nil)
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.Type != "INVALID_ARGUMENT" || apiErr.Message != "API key not valid." {
+		t.Errorf("got %v, want the decoded API error", err)
+	}
+}
+
+func TestBuildGeminiRequest(t *testing.T) {
+	image := ImagePart("chart.png", "image/png", []byte{0x89, 'P', 'N', 'G'})
+	req := buildGeminiRequest([]Message{
+		{Role: "assistant", Content: TextContent("Hello, how can I help?")},
+		{Role: "system", Content: TextContent("Be brief.")},
+		{Role: "user", Content: TextContent("First")},
+		{Role: "user", Content: TextContent("Second")},
+		{Role: "assistant", Content: TextContent("Answer")},
+	}, Content{{Type: PartText, Text: "What does this show?"}, image})
+
+	// A leading assistant turn is dropped and consecutive user turns merged
+	if len(req.Contents) != 3 {
+		t.Fatalf("contents = %+v, want user, model and user turns", req.Contents)
+	}
+	roles := []string{req.Contents[0].Role, req.Contents[1].Role, req.Contents[2].Role}
+	if roles[0] != "user" || roles[1] != "model" || roles[2] != "user" {
+		t.Errorf("roles = %v, want user, model, user", roles)
+	}
+	if len(req.Contents[0].Parts) != 2 {
+		t.Errorf("first turn has %d parts, want both user messages", len(req.Contents[0].Parts))
+	}
+	last := req.Contents[2].Parts
+	if len(last) != 2 || last[1].InlineData == nil || last[1].InlineData.MIMEType != "image/png" {
+		t.Errorf("last turn = %+v, want the text and the inline image", last)
+	}
+	if req.SystemInstruction == nil || req.SystemInstruction.Parts[0].Text != "Be brief." {
+		t.Errorf("systemInstruction = %+v", req.SystemInstruction)
+	}
+}
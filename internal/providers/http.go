@@ -0,0 +1,107 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// APIError is returned by the HTTP-based providers when the remote API
+// responds with a non-2xx status code.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Type       string
+	Message    string
+	Header     http.Header
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	if e.Type != "" {
+		return fmt.Sprintf("%s: error, status code: %d, type: %s, message: %s", e.Provider, e.StatusCode, e.Type, msg)
+	}
+	return fmt.Sprintf("%s: error, status code: %d, message: %s", e.Provider, e.StatusCode, msg)
+}
+
+// errorDecoder extracts the vendor-specific error type and message from an
+// error response body.
+type errorDecoder func(body []byte) (errType, message string)
+
+// httpAPI holds the shared plumbing for providers that talk to a JSON REST API
+// over plain net/http.
+type httpAPI struct {
+	provider    string
+	baseURL     string
+	client      *http.Client
+	header      http.Header
+	decodeError errorDecoder
+}
+
+func (a *httpAPI) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return a.do(ctx, http.MethodPost, path, bytes.NewReader(payload))
+}
+
+func (a *httpAPI) get(ctx context.Context, path string) (*http.Response, error) {
+	return a.do(ctx, http.MethodGet, path, nil)
+}
+
+func (a *httpAPI) do(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(a.baseURL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range a.header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := a.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		apiErr := &APIError{
+			Provider:   a.provider,
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+		}
+		if a.decodeError != nil {
+			apiErr.Type, apiErr.Message = a.decodeError(data)
+		}
+		if apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return nil, apiErr
+	}
+
+	return resp, nil
+}
+
+// decodeJSON reads the response body into v and closes it.
+func decodeJSON(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,50 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSendStopsWhenTheContextEnds(t *testing.T) {
+	// The server does not answer until the test ends, as a hung endpoint would
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	opts := Options{BaseURL: server.URL, APIKey: "test", Model: "test-model", Retry: RetryPolicy{MaxAttempts: 3}}
+	constructors := map[string]func(Options) (Provider, error){
+		"ollama":            func(opts Options) (Provider, error) { return NewOllama(opts) },
+		"anthropic":         func(opts Options) (Provider, error) { return NewAnthropic(opts) },
+		"openai-compatible": func(opts Options) (Provider, error) { return NewOpenAICompatible(opts) },
+	}
+	for name, newProvider := range constructors {
+		t.Run(name, func(t *testing.T) {
+			provider, err := newProvider(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			_, err = provider.Send(ctx, Request{Message: TextContent("Hi")}, nil)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("got %v, want the deadline error", err)
+			}
+			// A timed out request is not retried
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("Send returned after %s, want it to stop at the deadline", elapsed)
+			}
+		})
+	}
+}
@@ -1,32 +1,292 @@
 package providers
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
 )
 
+const (
+	huggingFaceInferenceURL = "https://api-inference.huggingface.co/models"
+	huggingFaceDefaultModel = "HuggingFaceH4/zephyr-7b-beta"
+	huggingFaceMaxNewTokens = 1024
+)
+
+// HuggingFace talks to either the hosted Inference API or a self-hosted
+// text-generation-inference (TGI) server when a base URL is configured.
 type HuggingFace struct {
-	apiKey string
-	model  string
+	api      httpAPI
+	model    string
+	template chatTemplate
+	tgi      bool
+}
+
+type huggingFaceParameters struct {
+	MaxNewTokens   int      `json:"max_new_tokens,omitempty"`
+	Stop           []string `json:"stop,omitempty"`
+	ReturnFullText *bool    `json:"return_full_text,omitempty"`
+	Details        bool     `json:"details,omitempty"`
+	Temperature    *float64 `json:"temperature,omitempty"`
+	TopP           *float64 `json:"top_p,omitempty"`
+	Seed           *int     `json:"seed,omitempty"`
+	DoSample       *bool    `json:"do_sample,omitempty"`
+	// Grammar constrains TGI's output, here to a JSON Schema
+	Grammar *huggingFaceGrammar `json:"grammar,omitempty"`
+}
+
+type huggingFaceGrammar struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+type huggingFaceRequest struct {
+	Inputs     string                `json:"inputs"`
+	Parameters huggingFaceParameters `json:"parameters"`
+	Stream     bool                  `json:"stream,omitempty"`
+}
+
+// huggingFaceDetails is only returned by TGI, which counts the generated
+// tokens but not the prompt
+type huggingFaceDetails struct {
+	GeneratedTokens int `json:"generated_tokens"`
+}
+
+type huggingFaceResponse struct {
+	GeneratedText string              `json:"generated_text"`
+	Details       *huggingFaceDetails `json:"details"`
+}
+
+func (r *huggingFaceResponse) usage() Usage {
+	if r.Details == nil {
+		return Usage{}
+	}
+	return Usage{CompletionTokens: r.Details.GeneratedTokens}
+}
+
+type huggingFaceStreamEvent struct {
+	Token struct {
+		Text    string `json:"text"`
+		Special bool   `json:"special"`
+	} `json:"token"`
+	GeneratedText *string             `json:"generated_text"`
+	Details       *huggingFaceDetails `json:"details"`
+	Error         string              `json:"error"`
+	ErrorType     string              `json:"error_type"`
 }
 
-func NewHuggingFace(apiKey, model string) (*HuggingFace, error) {
+func init() {
+	Register("huggingface", []string{"@huggingface/inference"}, func(opts Options) (Provider, error) {
+		return NewHuggingFace(opts)
+	})
+}
+
+func NewHuggingFace(opts Options) (*HuggingFace, error) {
+	model := opts.Model
 	if model == "" {
-		model = "gpt2"
+		model = huggingFaceDefaultModel
 	}
-	return &HuggingFace{
-		apiKey: apiKey,
-		model:  model,
-	}, nil
+
+	template, err := getChatTemplate(opts.ChatTemplate, model)
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	if opts.APIKey != "" {
+		header.Set("Authorization", "Bearer "+opts.APIKey)
+	}
+
+	// A configured base URL points at a TGI server, which serves a single
+	// model; otherwise requests go to the model's Inference API endpoint.
+	tgi := opts.BaseURL != ""
+	baseURL := opts.BaseURL
+	if !tgi {
+		baseURL = huggingFaceInferenceURL + "/" + model
+	}
+
+	h := &HuggingFace{
+		api: httpAPI{
+			provider:    "huggingface",
+			baseURL:     baseURL,
+			header:      header,
+			decodeError: decodeHuggingFaceError,
+		},
+		model:    model,
+		template: template,
+		tgi:      tgi,
+	}
+	h.api.client = newRetryClient(nil, opts.Retry, h.HandleRateLimiting)
+
+	return h, nil
 }
 
-func (h *HuggingFace) Send(history []Message, message interface{}, stream bool) (string, error) {
-	return "", fmt.Errorf("huggingface provider not yet implemented - requires huggingface-go client")
+func (h *HuggingFace) Send(ctx context.Context, request Request, sink StreamSink) (*Response, error) {
+	if err := toolsUnsupported("huggingface", request); err != nil {
+		return nil, err
+	}
+	if err := imagesUnsupported("huggingface", request); err != nil {
+		return nil, err
+	}
+
+	prompt := h.template.render(toTemplateMessages(request.History, request.Message))
+
+	returnFullText := false
+	req := huggingFaceRequest{
+		Inputs:     prompt,
+		Parameters: h.parameters(request.Sampling),
+	}
+
+	path := ""
+	if h.tgi {
+		path = "/generate"
+		req.Parameters.Details = true
+		if request.ResponseFormat != nil && len(request.ResponseFormat.Schema) > 0 {
+			req.Parameters.Grammar = &huggingFaceGrammar{Type: "json", Value: request.ResponseFormat.Schema}
+		}
+		if sink != nil {
+			path = "/generate_stream"
+		}
+	} else {
+		req.Parameters.ReturnFullText = &returnFullText
+		req.Stream = sink != nil
+	}
+
+	resp, err := h.api.post(ctx, path, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if sink != nil {
+		return h.handleStreamingResponse(ctx, resp, sink)
+	}
+	return h.handleSingleResponse(resp)
 }
 
 func (h *HuggingFace) SupportsStreaming() bool {
-	return false
+	return true
+}
+
+func (h *HuggingFace) Model() string {
+	return h.model
 }
 
 func (h *HuggingFace) HandleRateLimiting(err error) error {
-	return err
+	return classifyError(err)
+}
+
+func (h *HuggingFace) handleStreamingResponse(ctx context.Context, resp *http.Response, sink StreamSink) (*Response, error) {
+	defer resp.Body.Close()
+
+	reader := newSSEReader(resp.Body)
+	var fullResponse strings.Builder
+	var usage Usage
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, sink.fail(ctx, err)
+		}
+		if event.Data == "" {
+			continue
+		}
+
+		var payload huggingFaceStreamEvent
+		if err := json.Unmarshal([]byte(event.Data), &payload); err != nil {
+			return nil, sink.fail(ctx, fmt.Errorf("huggingface: invalid stream event: %w", err))
+		}
+		if payload.Error != "" {
+			return nil, sink.fail(ctx, &APIError{
+				Provider: "huggingface",
+				Type:     payload.ErrorType,
+				Message:  payload.Error,
+			})
+		}
+
+		if payload.Details != nil {
+			usage.CompletionTokens = payload.Details.GeneratedTokens
+		}
+
+		// Special tokens (end of sequence, stop markers) are not part of the answer
+		if payload.Token.Special {
+			continue
+		}
+		sink.text(payload.Token.Text)
+		fullResponse.WriteString(payload.Token.Text)
+	}
+	sink.usage(usage)
+	sink.done()
+	return &Response{Content: h.trimStop(fullResponse.String()), Usage: usage}, nil
+}
+
+func (h *HuggingFace) handleSingleResponse(resp *http.Response) (*Response, error) {
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// TGI returns a single object, the Inference API a list of generations
+	var result huggingFaceResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		var results []huggingFaceResponse
+		if err := json.Unmarshal(data, &results); err != nil {
+			return nil, fmt.Errorf("huggingface: invalid response: %w", err)
+		}
+		if len(results) == 0 {
+			return nil, fmt.Errorf("huggingface: empty response")
+		}
+		result = results[0]
+	}
+
+	return &Response{Content: h.trimStop(result.GeneratedText), Usage: result.usage()}, nil
+}
+
+// parameters maps sampling onto the generation parameters. TGI rejects a
+// temperature of 0, so it is expressed as greedy decoding instead.
+func (h *HuggingFace) parameters(sampling Sampling) huggingFaceParameters {
+	params := huggingFaceParameters{
+		MaxNewTokens: huggingFaceMaxNewTokens,
+		Stop:         append(append([]string{}, h.template.stop...), sampling.Stop...),
+		TopP:         sampling.TopP,
+		Seed:         sampling.Seed,
+	}
+	if sampling.MaxTokens > 0 {
+		params.MaxNewTokens = sampling.MaxTokens
+	}
+
+	if sampling.Temperature != nil {
+		doSample := *sampling.Temperature > 0
+		params.DoSample = &doSample
+		if doSample {
+			params.Temperature = sampling.Temperature
+		}
+	} else if sampling.TopP != nil || sampling.Seed != nil {
+		doSample := true
+		params.DoSample = &doSample
+	}
+	return params
+}
+
+// trimStop removes a trailing stop sequence that some servers include in the output.
+func (h *HuggingFace) trimStop(text string) string {
+	for _, stop := range h.template.stop {
+		text = strings.TrimSuffix(text, stop)
+	}
+	return strings.TrimSpace(text)
+}
+
+func decodeHuggingFaceError(body []byte) (string, string) {
+	var payload struct {
+		Error     string `json:"error"`
+		ErrorType string `json:"error_type"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", ""
+	}
+	return payload.ErrorType, payload.Error
 }
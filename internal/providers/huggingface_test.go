@@ -0,0 +1,168 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTGIServer stands in for a text-generation-inference server, passing
+// each decoded request and its path to handler
+func newTGIServer(t *testing.T, opts Options, handler func(w http.ResponseWriter, path string, req huggingFaceRequest)) *HuggingFace {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req huggingFaceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		handler(w, r.URL.Path, req)
+	}))
+	t.Cleanup(server.Close)
+
+	opts.BaseURL = server.URL
+	opts.Retry = RetryPolicy{MaxAttempts: 1}
+	h, err := NewHuggingFace(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestTGIGenerate(t *testing.T) {
+	h := newTGIServer(t, Options{}, func(w http.ResponseWriter, path string, req huggingFaceRequest) {
+		if path != "/generate" {
+			t.Errorf("path = %q, want /generate", path)
+		}
+		// The default model is a zephyr model, so the prompt uses its format
+		want := "<|system|>\nBe brief.</s>\n<|user|>\nHi</s>\n<|assistant|>\n"
+		if req.Inputs != want {
+			t.Errorf("inputs = %q, want %q", req.Inputs, want)
+		}
+		if !req.Parameters.Details || req.Parameters.ReturnFullText != nil {
+			t.Errorf("parameters = %+v, want details and no return_full_text", req.Parameters)
+		}
+		if req.Parameters.Grammar == nil || req.Parameters.Grammar.Type != "json" {
+			t.Errorf("grammar = %+v, want the JSON schema", req.Parameters.Grammar)
+		}
+		io.WriteString(w, `{"generated_text":"{\"ok\":true}</s>","details":{"generated_tokens":7}}`)
+	})
+
+	resp, err := h.Send(context.Background(), Request{
+		History:        []Message{{Role: "system", Content: TextContent("Be brief.")}},
+		Message:        TextContent("Hi"),
+		ResponseFormat: &ResponseFormat{Schema: json.RawMessage(`{"type":"object"}`)},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != `{"ok":true}` || resp.Usage.CompletionTokens != 7 {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestTGIGenerateStream(t *testing.T) {
+	h := newTGIServer(t, Options{Model: "meta-llama/Meta-Llama-3-8B-Instruct"}, func(w http.ResponseWriter, path string, req huggingFaceRequest) {
+		if path != "/generate_stream" {
+			t.Errorf("path = %q, want /generate_stream", path)
+		}
+		if !strings.HasPrefix(req.Inputs, "<|begin_of_text|>") {
+			t.Errorf("inputs = %q, want the llama3 format", req.Inputs)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data:{\"token\":{\"text\":\"Hel\",\"special\":false}}\n\n"+
+			"data:{\"token\":{\"text\":\"lo\",\"special\":false}}\n\n"+
+			"data:{\"token\":{\"text\":\"<|eot_id|>\",\"special\":true},\"generated_text\":\"Hello\",\"details\":{\"generated_tokens\":3}}\n\n")
+	})
+
+	var streamed strings.Builder
+	var usage *Usage
+	sink := func(event StreamEvent) {
+		switch event.Type {
+		case EventTextDelta:
+			streamed.WriteString(event.Text)
+		case EventUsage:
+			usage = event.Usage
+		}
+	}
+	resp, err := h.Send(context.Background(), Request{Message: TextContent("Hi")}, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Hello" || streamed.String() != "Hello" {
+		t.Errorf("content = %q, streamed %q, want Hello", resp.Content, streamed.String())
+	}
+	if usage == nil || usage.CompletionTokens != 3 {
+		t.Errorf("usage = %+v, want 3 completion tokens", usage)
+	}
+}
+
+func TestTGIStreamError(t *testing.T) {
+	h := newTGIServer(t, Options{}, func(w http.ResponseWriter, path string, req huggingFaceRequest) {
+		io.WriteString(w, "data:{\"error\":\"Input validation error\",\"error_type\":\"validation\"}\n\n")
+	})
+
+	_, err := h.Send(context.Background(), Request{Message: TextContent("Hi")}, func(StreamEvent) {})
+	if err == nil || !strings.Contains(err.Error(), "Input validation error") {
+		t.Fatalf("err = %v, want the validation error", err)
+	}
+}
+
+func TestTGIGreedyDecoding(t *testing.T) {
+	h := newTGIServer(t, Options{ChatTemplate: "plain"}, func(w http.ResponseWriter, path string, req huggingFaceRequest) {
+		params := req.Parameters
+		if params.DoSample == nil || *params.DoSample || params.Temperature != nil {
+			t.Errorf("parameters = %+v, want greedy decoding without a temperature", params)
+		}
+		if params.MaxNewTokens != 50 {
+			t.Errorf("max_new_tokens = %d, want 50", params.MaxNewTokens)
+		}
+		io.WriteString(w, `{"generated_text":" Hi"}`)
+	})
+
+	temperature := 0.0
+	resp, err := h.Send(context.Background(), Request{
+		Message:  TextContent("Hi"),
+		Sampling: Sampling{Temperature: &temperature, MaxTokens: 50},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Hi" {
+		t.Errorf("content = %q, want Hi", resp.Content)
+	}
+}
+
+func TestTemplateForModel(t *testing.T) {
+	tests := map[string]string{
+		"":                                    defaultChatTemplate,
+		huggingFaceDefaultModel:               "zephyr",
+		"meta-llama/Meta-Llama-3-8B-Instruct": "llama3",
+		"mistralai/Mistral-7B-Instruct-v0.3":  "mistral",
+		"mistralai/Mixtral-8x7B-Instruct":     "mistral",
+		"Qwen/Qwen2-7B-Instruct":              "chatml",
+	}
+	for model, want := range tests {
+		if got := templateForModel(model); got != want {
+			t.Errorf("templateForModel(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
+
+func TestTGIContinuesHistory(t *testing.T) {
+	// A nil message, as sent when retrying an answer, adds no empty user turn
+	h := newTGIServer(t, Options{}, func(w http.ResponseWriter, path string, req huggingFaceRequest) {
+		want := "<|user|>\nHi</s>\n<|assistant|>\n"
+		if req.Inputs != want {
+			t.Errorf("inputs = %q, want %q", req.Inputs, want)
+		}
+		io.WriteString(w, `{"generated_text":"Hello"}`)
+	})
+
+	if _, err := h.Send(context.Background(), Request{History: []Message{{Role: "user", Content: TextContent("Hi")}}}, nil); err != nil {
+		t.Fatal(err)
+	}
+}
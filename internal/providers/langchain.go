@@ -1,6 +1,7 @@
 package providers
 
 import (
+	"context"
 	"fmt"
 )
 
@@ -9,21 +10,31 @@ type Langchain struct {
 	model  string
 }
 
-func NewLangchain(apiKey, model string) (*Langchain, error) {
+func init() {
+	Register("langchain", nil, func(opts Options) (Provider, error) {
+		return NewLangchain(opts)
+	})
+}
+
+func NewLangchain(opts Options) (*Langchain, error) {
 	return &Langchain{
-		apiKey: apiKey,
-		model:  model,
+		apiKey: opts.APIKey,
+		model:  opts.Model,
 	}, nil
 }
 
-func (l *Langchain) Send(history []Message, message interface{}, stream bool) (string, error) {
-	return "", fmt.Errorf("langchain provider not yet implemented - requires langchain-go implementation")
+func (l *Langchain) Send(ctx context.Context, request Request, sink StreamSink) (*Response, error) {
+	return nil, fmt.Errorf("langchain provider not yet implemented - requires langchain-go implementation")
 }
 
 func (l *Langchain) SupportsStreaming() bool {
 	return false
 }
 
+func (l *Langchain) Model() string {
+	return l.model
+}
+
 func (l *Langchain) HandleRateLimiting(err error) error {
-	return err
+	return classifyError(err)
 }
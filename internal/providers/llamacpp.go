@@ -0,0 +1,26 @@
+package providers
+
+const llamaCppBaseURL = "http://localhost:8080/v1"
+
+// LlamaCpp talks to a llama.cpp server through its OpenAI-compatible API.
+type LlamaCpp struct {
+	openAIChat
+}
+
+func init() {
+	Register("llama.cpp", []string{"llamacpp"}, func(opts Options) (Provider, error) {
+		return NewLlamaCpp(opts)
+	})
+}
+
+func NewLlamaCpp(opts Options) (*LlamaCpp, error) {
+	// The server only hosts the model it was started with, but the API
+	// still requires a model name to be sent
+	if opts.Model == "" {
+		opts.Model = "default"
+	}
+
+	return &LlamaCpp{
+		openAIChat: newOpenAIChat("llama.cpp", opts, llamaCppBaseURL),
+	}, nil
+}
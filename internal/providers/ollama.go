@@ -0,0 +1,233 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	ollamaBaseURL      = "http://localhost:11434"
+	ollamaDefaultModel = "llama3"
+)
+
+// Ollama talks to a local Ollama server through its native chat API, so no
+// hosted service or API key is needed.
+type Ollama struct {
+	api   httpAPI
+	model string
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// Images are the message's images, base64-encoded
+	Images [][]byte `json:"images,omitempty"`
+}
+
+type ollamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  *ollamaOptions  `json:"options,omitempty"`
+	// Format is "json" to constrain the output to JSON
+	Format string `json:"format,omitempty"`
+}
+
+type ollamaResponse struct {
+	Message ollamaMessage `json:"message"`
+	Done    bool          `json:"done"`
+	Error   string        `json:"error"`
+	// The token counts are only set on the final chunk
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+func (r *ollamaResponse) usage() Usage {
+	return Usage{PromptTokens: r.PromptEvalCount, CompletionTokens: r.EvalCount}
+}
+
+func init() {
+	Register("ollama", nil, func(opts Options) (Provider, error) {
+		return NewOllama(opts)
+	})
+}
+
+func NewOllama(opts Options) (*Ollama, error) {
+	model := opts.Model
+	if model == "" {
+		model = ollamaDefaultModel
+	}
+
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = ollamaBaseURL
+	}
+
+	// Ollama itself is unauthenticated, but it is often fronted by a proxy that is not
+	header := http.Header{}
+	if opts.APIKey != "" {
+		header.Set("Authorization", "Bearer "+opts.APIKey)
+	}
+
+	o := &Ollama{
+		api: httpAPI{
+			provider:    "ollama",
+			baseURL:     baseURL,
+			header:      header,
+			decodeError: decodeOllamaError,
+		},
+		model: model,
+	}
+	o.api.client = newRetryClient(nil, opts.Retry, o.HandleRateLimiting)
+
+	return o, nil
+}
+
+func (o *Ollama) Send(ctx context.Context, request Request, sink StreamSink) (*Response, error) {
+	if err := toolsUnsupported("ollama", request); err != nil {
+		return nil, err
+	}
+
+	messages := make([]ollamaMessage, 0, len(request.History)+1)
+	for _, msg := range request.History {
+		messages = append(messages, newOllamaMessage(msg.Role, msg.Content))
+	}
+	if request.Message != nil {
+		messages = append(messages, newOllamaMessage("user", request.Message))
+	}
+
+	req := ollamaRequest{
+		Model:    o.model,
+		Messages: messages,
+		Stream:   sink != nil,
+	}
+	if request.ResponseFormat != nil {
+		req.Format = "json"
+	}
+	if sampling := request.Sampling; !sampling.IsZero() {
+		req.Options = &ollamaOptions{
+			Temperature: sampling.Temperature,
+			TopP:        sampling.TopP,
+			Seed:        sampling.Seed,
+			NumPredict:  sampling.MaxTokens,
+			Stop:        sampling.Stop,
+		}
+	}
+
+	resp, err := o.api.post(ctx, "/api/chat", req)
+	if err != nil {
+		return nil, err
+	}
+
+	if sink != nil {
+		return o.handleStreamingResponse(ctx, resp, sink)
+	}
+	return o.handleSingleResponse(resp)
+}
+
+// newOllamaMessage flattens content to text, passing images alongside for
+// vision models
+func newOllamaMessage(role string, content Content) ollamaMessage {
+	message := ollamaMessage{Role: role, Content: content.Text()}
+	for _, image := range content.Parts(PartImage) {
+		message.Images = append(message.Images, image.Data)
+	}
+	return message
+}
+
+func (o *Ollama) SupportsStreaming() bool {
+	return true
+}
+
+func (o *Ollama) Model() string {
+	return o.model
+}
+
+func (o *Ollama) HandleRateLimiting(err error) error {
+	return classifyError(err)
+}
+
+// ListModels returns the models pulled on the Ollama server.
+func (o *Ollama) ListModels(ctx context.Context) ([]string, error) {
+	resp, err := o.api.get(ctx, "/api/tags")
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := decodeJSON(resp, &result); err != nil {
+		return nil, fmt.Errorf("ollama: invalid model list: %w", err)
+	}
+
+	models := make([]string, len(result.Models))
+	for i, model := range result.Models {
+		models[i] = model.Name
+	}
+	return models, nil
+}
+
+func (o *Ollama) handleStreamingResponse(ctx context.Context, resp *http.Response, sink StreamSink) (*Response, error) {
+	defer resp.Body.Close()
+
+	// Ollama streams newline-delimited JSON chunks
+	decoder := json.NewDecoder(resp.Body)
+	var fullResponse strings.Builder
+	var usage Usage
+	for {
+		var chunk ollamaResponse
+		err := decoder.Decode(&chunk)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, sink.fail(ctx, fmt.Errorf("ollama: invalid stream chunk: %w", err))
+		}
+		if chunk.Error != "" {
+			return nil, sink.fail(ctx, &APIError{Provider: "ollama", Message: chunk.Error})
+		}
+
+		sink.text(chunk.Message.Content)
+		fullResponse.WriteString(chunk.Message.Content)
+		if chunk.Done {
+			usage = chunk.usage()
+			break
+		}
+	}
+	sink.usage(usage)
+	sink.done()
+	return &Response{Content: fullResponse.String(), Usage: usage}, nil
+}
+
+func (o *Ollama) handleSingleResponse(resp *http.Response) (*Response, error) {
+	var result ollamaResponse
+	if err := decodeJSON(resp, &result); err != nil {
+		return nil, fmt.Errorf("ollama: invalid response: %w", err)
+	}
+	return &Response{Content: result.Message.Content, Usage: result.usage()}, nil
+}
+
+func decodeOllamaError(body []byte) (string, string) {
+	var payload struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", ""
+	}
+	return "", payload.Error
+}
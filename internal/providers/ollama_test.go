@@ -0,0 +1,102 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOllamaStreaming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("path = %q, want /api/chat", r.URL.Path)
+		}
+		var req ollamaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		if req.Model != ollamaDefaultModel || !req.Stream {
+			t.Errorf("model = %q, stream = %v, want the default model streamed", req.Model, req.Stream)
+		}
+		// Chunks are newline-delimited JSON, the last one marked done
+		io.WriteString(w, `{"message":{"role":"assistant","content":"Hel"},"done":false}`+"\n")
+		io.WriteString(w, `{"message":{"role":"assistant","content":"lo"},"done":false}`+"\n")
+		io.WriteString(w, `{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":12,"eval_count":2}`+"\n")
+	}))
+	defer server.Close()
+
+	provider, err := NewOllama(Options{BaseURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streamed strings.Builder
+	sink := func(event StreamEvent) {
+		if event.Type == EventTextDelta {
+			streamed.WriteString(event.Text)
+		}
+	}
+	resp, err := provider.Send(context.Background(), Request{Message: TextContent("Hi")}, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Hello" || streamed.String() != "Hello" {
+		t.Errorf("content = %q, streamed %q, want Hello", resp.Content, streamed.String())
+	}
+	if resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 2 {
+		t.Errorf("usage = %+v, want 12 prompt and 2 completion tokens", resp.Usage)
+	}
+}
+
+func TestOllamaError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":"model \"llama3\" not found, try pulling it first"}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewOllama(Options{BaseURL: server.URL, Retry: RetryPolicy{MaxAttempts: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = provider.Send(context.Background(), Request{Message: TextContent("Hi")}, nil)
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != `model "llama3" not found, try pulling it first` {
+		t.Errorf("got %v, want the decoded not found error", err)
+	}
+}
+
+func TestOllamaContinuesHistory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		// A nil message, as sent when retrying an answer, adds no empty user turn
+		if len(req.Messages) != 2 || req.Messages[1].Role != "user" || req.Messages[1].Content != "Hi" {
+			t.Errorf("messages = %+v, want the history ending with the user's Hi", req.Messages)
+		}
+		io.WriteString(w, `{"message":{"role":"assistant","content":"Hello"},"done":true,"prompt_eval_count":12,"eval_count":2}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewOllama(Options{BaseURL: server.URL, Retry: RetryPolicy{MaxAttempts: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := provider.Send(context.Background(), Request{
+		History: []Message{
+			{Role: "system", Content: TextContent("Be brief.")},
+			{Role: "user", Content: TextContent("Hi")},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Hello" || resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 2 {
+		t.Errorf("response = %+v", resp)
+	}
+}
@@ -0,0 +1,373 @@
+package providers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// OpenAICompatible talks to any server that speaks the OpenAI chat
+// completions wire protocol, such as vLLM, LiteLLM or an internal gateway.
+type OpenAICompatible struct {
+	openAIChat
+}
+
+func init() {
+	Register("openai-compatible", nil, func(opts Options) (Provider, error) {
+		return NewOpenAICompatible(opts)
+	})
+}
+
+func NewOpenAICompatible(opts Options) (*OpenAICompatible, error) {
+	if opts.BaseURL == "" {
+		return nil, fmt.Errorf("openai-compatible provider requires BASE_URL to be set")
+	}
+	if opts.Model == "" {
+		return nil, fmt.Errorf("openai-compatible provider requires MODEL to be set")
+	}
+
+	return &OpenAICompatible{
+		openAIChat: newOpenAIChat("openai-compatible", opts, ""),
+	}, nil
+}
+
+// openAIChat implements Provider on top of the go-openai client. It is shared
+// by every provider that speaks the OpenAI wire protocol.
+type openAIChat struct {
+	name   string
+	client *openai.Client
+	model  string
+	// streamUsage asks for a final usage chunk in streamed responses
+	streamUsage bool
+	// maxCompletionTokens sends the output limit as max_completion_tokens,
+	// which OpenAI's reasoning models require in place of max_tokens
+	maxCompletionTokens bool
+	// userRolesOnly is set for models that accept only user and assistant
+	// messages and fix temperature and top_p, such as o1-mini
+	userRolesOnly bool
+}
+
+// newOpenAIChat builds a client from opts, using defaultBaseURL when no
+// BaseURL is configured. Headers, organization, project and API version are
+// applied to every request.
+func newOpenAIChat(name string, opts Options, defaultBaseURL string) openAIChat {
+	config := openai.DefaultConfig(opts.APIKey)
+	if opts.BaseURL != "" {
+		config.BaseURL = opts.BaseURL
+	} else if defaultBaseURL != "" {
+		config.BaseURL = defaultBaseURL
+	}
+	config.OrgID = opts.Organization
+
+	return newOpenAIChatWithConfig(name, config, opts)
+}
+
+// newOpenAIChatWithConfig builds a client from a prepared config, adding the
+// extra headers and retry policy from opts.
+func newOpenAIChatWithConfig(name string, config openai.ClientConfig, opts Options) openAIChat {
+	chat := openAIChat{
+		name:        name,
+		model:       opts.Model,
+		streamUsage: opts.StreamUsage,
+	}
+	config.HTTPClient = newRetryClient(newHeaderTransport(opts), opts.Retry, chat.HandleRateLimiting)
+	chat.client = openai.NewClientWithConfig(config)
+
+	return chat
+}
+
+func (o *openAIChat) Send(ctx context.Context, request Request, sink StreamSink) (*Response, error) {
+	messages := make([]openai.ChatCompletionMessage, 0, len(request.History)+1)
+	for _, msg := range request.History {
+		messages = append(messages, openAIMessages(msg.Role, msg.Content)...)
+	}
+	if request.Message != nil {
+		messages = append(messages, openAIMessages(openai.ChatMessageRoleUser, request.Message)...)
+	}
+
+	req := openai.ChatCompletionRequest{
+		Model:       o.model,
+		Messages:    messages,
+		Temperature: openAIFloat(request.Sampling.Temperature),
+		TopP:        openAIFloat(request.Sampling.TopP),
+		Seed:        request.Sampling.Seed,
+		Stop:        request.Sampling.Stop,
+	}
+	if o.maxCompletionTokens {
+		req.MaxCompletionTokens = request.Sampling.MaxTokens
+	} else {
+		req.MaxTokens = request.Sampling.MaxTokens
+	}
+	if o.userRolesOnly {
+		req.Messages = foldSystemMessages(req.Messages)
+		req.Temperature, req.TopP = 0, 0
+	}
+	if request.ResponseFormat != nil {
+		req.ResponseFormat = &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+	}
+	for _, tool := range request.Tools {
+		req.Tools = append(req.Tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.schemaOrEmpty(),
+			},
+		})
+	}
+
+	if sink != nil {
+		if o.streamUsage {
+			req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+		}
+		return o.handleStreamingResponse(ctx, req, sink)
+	}
+	return o.handleSingleResponse(ctx, req)
+}
+
+// openAIMessages maps a message onto the chat format. Each tool result
+// becomes a "tool" message of its own, and content with images is sent as
+// parts with the images inlined as data URLs.
+func openAIMessages(role string, content Content) []openai.ChatCompletionMessage {
+	var messages []openai.ChatCompletionMessage
+	for _, result := range content.Parts(PartToolResult) {
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:       openai.ChatMessageRoleTool,
+			Content:    result.Text,
+			ToolCallID: result.ToolCallID,
+		})
+	}
+	if role == openai.ChatMessageRoleTool {
+		return messages
+	}
+
+	message := openai.ChatCompletionMessage{Role: role}
+	if images := content.Parts(PartImage); len(images) > 0 {
+		if text := content.Text(); text != "" {
+			message.MultiContent = append(message.MultiContent, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: text})
+		}
+		for _, image := range images {
+			message.MultiContent = append(message.MultiContent, openai.ChatMessagePart{
+				Type:     openai.ChatMessagePartTypeImageURL,
+				ImageURL: &openai.ChatMessageImageURL{URL: "data:" + image.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(image.Data)},
+			})
+		}
+	} else {
+		message.Content = content.Text()
+	}
+	for _, call := range content.ToolCalls() {
+		message.ToolCalls = append(message.ToolCalls, openai.ToolCall{
+			ID:       call.ID,
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: call.Name, Arguments: call.Arguments},
+		})
+	}
+	return append(messages, message)
+}
+
+// openAIFloat converts an optional parameter for the client library, which
+// omits zero values. An explicit 0 is sent as the smallest positive float32
+// instead, which the API treats the same as 0.
+func openAIFloat(value *float64) float32 {
+	if value == nil {
+		return 0
+	}
+	if *value == 0 {
+		return math.SmallestNonzeroFloat32
+	}
+	return float32(*value)
+}
+
+// foldSystemMessages moves the text of system messages to the start of the
+// first user message, for models that reject the system role
+func foldSystemMessages(messages []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	var system []string
+	folded := make([]openai.ChatCompletionMessage, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Role != openai.ChatMessageRoleSystem {
+			folded = append(folded, msg)
+			continue
+		}
+		text := msg.Content
+		for _, part := range msg.MultiContent {
+			if part.Type == openai.ChatMessagePartTypeText {
+				text += part.Text
+			}
+		}
+		system = append(system, text)
+	}
+	if len(system) == 0 {
+		return messages
+	}
+
+	prefix := strings.Join(system, "\n\n")
+	for i, msg := range folded {
+		if msg.Role != openai.ChatMessageRoleUser {
+			continue
+		}
+		if msg.MultiContent != nil {
+			folded[i].MultiContent = append([]openai.ChatMessagePart{{Type: openai.ChatMessagePartTypeText, Text: prefix}}, msg.MultiContent...)
+		} else {
+			folded[i].Content = prefix + "\n\n" + msg.Content
+		}
+		return folded
+	}
+	return append(folded, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: prefix})
+}
+
+// SupportsStreaming is false for models the catalog lists without streaming,
+// such as o1-mini
+func (o *openAIChat) SupportsStreaming() bool {
+	if info, ok := LookupModel(o.name, o.model); ok {
+		return info.Streaming
+	}
+	return true
+}
+
+func (o *openAIChat) Model() string {
+	return o.model
+}
+
+func (o *openAIChat) HandleRateLimiting(err error) error {
+	return classifyError(err)
+}
+
+// ListModels returns the models served behind the endpoint.
+func (o *openAIChat) ListModels(ctx context.Context) ([]string, error) {
+	list, err := o.client.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	models := make([]string, len(list.Models))
+	for i, model := range list.Models {
+		models[i] = model.ID
+	}
+	return models, nil
+}
+
+// handleStreamingResponse relays the stream to sink. Usage arrives in a final
+// chunk without choices when it was requested; otherwise the session
+// estimates it.
+func (o *openAIChat) handleStreamingResponse(ctx context.Context, req openai.ChatCompletionRequest, sink StreamSink) (*Response, error) {
+	stream, err := o.client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var fullResponse strings.Builder
+	var toolCalls toolCallBuilder
+	var usage Usage
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, sink.fail(ctx, err)
+		}
+		if response.Usage != nil {
+			usage = Usage{
+				PromptTokens:     response.Usage.PromptTokens,
+				CompletionTokens: response.Usage.CompletionTokens,
+			}
+		}
+		if len(response.Choices) == 0 {
+			continue
+		}
+
+		delta := response.Choices[0].Delta
+		sink.text(delta.Content)
+		fullResponse.WriteString(delta.Content)
+
+		for _, call := range delta.ToolCalls {
+			index := 0
+			if call.Index != nil {
+				index = *call.Index
+			}
+			toolCall := ToolCallDelta{
+				Index:     index,
+				ID:        call.ID,
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			}
+			toolCalls.add(toolCall)
+			sink.toolCall(toolCall)
+		}
+	}
+	sink.usage(usage)
+	sink.done()
+	return &Response{Content: fullResponse.String(), ToolCalls: toolCalls.calls, Usage: usage}, nil
+}
+
+func (o *openAIChat) handleSingleResponse(ctx context.Context, req openai.ChatCompletionRequest) (*Response, error) {
+	resp, err := o.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("%s: empty response", o.name)
+	}
+
+	var toolCalls []ToolCall
+	for _, call := range resp.Choices[0].Message.ToolCalls {
+		toolCalls = append(toolCalls, ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+	}
+
+	return &Response{
+		Content:   resp.Choices[0].Message.Content,
+		ToolCalls: toolCalls,
+		Usage: Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+		},
+	}, nil
+}
+
+// headerTransport decorates outgoing requests with the configured extra
+// headers, project ID and api-version query parameter.
+type headerTransport struct {
+	base       http.RoundTripper
+	header     http.Header
+	apiVersion string
+}
+
+func newHeaderTransport(opts Options) *headerTransport {
+	header := http.Header{}
+	for key, value := range opts.Headers {
+		header.Set(key, value)
+	}
+	if opts.Project != "" {
+		header.Set("OpenAI-Project", opts.Project)
+	}
+
+	return &headerTransport{
+		base:       http.DefaultTransport,
+		header:     header,
+		apiVersion: opts.APIVersion,
+	}
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.header) == 0 && t.apiVersion == "" {
+		return t.base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	for key, values := range t.header {
+		req.Header[key] = values
+	}
+	if t.apiVersion != "" {
+		query := req.URL.Query()
+		query.Set("api-version", t.apiVersion)
+		req.URL.RawQuery = query.Encode()
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,259 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestOpenAICompatibleGateway(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" || r.URL.Query().Get("api-version") != "2024-06-01" {
+			t.Errorf("url = %q, want chat completions with the api-version", r.URL)
+		}
+		for key, want := range map[string]string{
+			"Authorization":  "Bearer test-key",
+			"X-Team":         "devops",
+			"OpenAI-Project": "proj_123",
+		} {
+			if got := r.Header.Get(key); got != want {
+				t.Errorf("%s = %q, want %q", key, got, want)
+			}
+		}
+		var req struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		if req.Model != "local-model" || len(req.Messages) != 2 || req.Messages[1].Content != "Hi" {
+			t.Errorf("request = %+v", req)
+		}
+		io.WriteString(w, `{"id":"chatcmpl-1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewOpenAICompatible(Options{
+		APIKey:     "test-key",
+		BaseURL:    server.URL,
+		Model:      "local-model",
+		Headers:    map[string]string{"X-Team": "devops"},
+		Project:    "proj_123",
+		APIVersion: "2024-06-01",
+		Retry:      RetryPolicy{MaxAttempts: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := provider.Send(context.Background(), Request{History: []Message{{Role: "system", Content: TextContent("Be brief.")}}, Message: TextContent("Hi")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Hello" {
+		t.Errorf("content = %q, want Hello", resp.Content)
+	}
+}
+
+func TestOpenAICompatibleRequiresBaseURLAndModel(t *testing.T) {
+	if _, err := NewOpenAICompatible(Options{Model: "local-model"}); err == nil {
+		t.Error("a provider without BASE_URL was created")
+	}
+	if _, err := NewOpenAICompatible(Options{BaseURL: "http://localhost:8000/v1"}); err == nil {
+		t.Error("a provider without MODEL was created")
+	}
+}
+
+// openAIStream is a streamed chat completion ending with the usage chunk
+// sent when stream_options.include_usage is set
+const openAIStream = `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}],"usage":null}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}],"usage":null}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":2,"total_tokens":14}}
+
+data: [DONE]
+
+`
+
+func TestOpenAIStreamUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Stream        bool `json:"stream"`
+			StreamOptions struct {
+				IncludeUsage bool `json:"include_usage"`
+			} `json:"stream_options"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		if !req.Stream || !req.StreamOptions.IncludeUsage {
+			t.Errorf("request = %+v, want a stream including usage", req)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, openAIStream)
+	}))
+	defer server.Close()
+
+	provider, err := NewOpenAICompatible(Options{BaseURL: server.URL, Model: "local-model", StreamUsage: true, Retry: RetryPolicy{MaxAttempts: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var streamed strings.Builder
+	var usage *Usage
+	sink := func(event StreamEvent) {
+		switch event.Type {
+		case EventTextDelta:
+			streamed.WriteString(event.Text)
+		case EventUsage:
+			usage = event.Usage
+		}
+	}
+	resp, err := provider.Send(context.Background(), Request{Message: TextContent("Hi")}, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Hello" || streamed.String() != "Hello" {
+		t.Errorf("content = %q, streamed %q, want Hello", resp.Content, streamed.String())
+	}
+	if resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 2 {
+		t.Errorf("usage = %+v, want 12 prompt and 2 completion tokens", resp.Usage)
+	}
+	if usage == nil || *usage != resp.Usage {
+		t.Errorf("sink usage = %+v, want %+v", usage, resp.Usage)
+	}
+}
+
+func TestStreamOptionsOptIn(t *testing.T) {
+	var sent bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		_, sent = req["stream_options"]
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		provider    string
+		streamUsage bool
+		want        bool
+	}{
+		{"openai", false, true},
+		{"openai-compatible", false, false},
+		{"openai-compatible", true, true},
+		{"llama.cpp", false, false},
+		{"llama.cpp", true, true},
+		{"openrouter", false, false},
+	}
+	for _, test := range tests {
+		provider, err := New(test.provider, Options{BaseURL: server.URL, Model: "gpt-4o", StreamUsage: test.streamUsage, Retry: RetryPolicy{MaxAttempts: 1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sent = false
+		if _, err := provider.Send(context.Background(), Request{Message: TextContent("Hi")}, func(StreamEvent) {}); err != nil {
+			t.Fatalf("%s: %v", test.provider, err)
+		}
+		if sent != test.want {
+			t.Errorf("%s with STREAM_USAGE %v sent stream_options: %v, want %v", test.provider, test.streamUsage, sent, test.want)
+		}
+	}
+}
+
+func TestMaxTokensParameter(t *testing.T) {
+	var req map[string]json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = nil
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"}}]}`)
+	}))
+	defer server.Close()
+
+	// OpenAI's reasoning models reject max_tokens, while other servers may
+	// not know max_completion_tokens
+	tests := map[string]struct{ model, field string }{
+		"openai":            {"o1-mini", "max_completion_tokens"},
+		"openai-compatible": {"local-model", "max_tokens"},
+	}
+	for name, test := range tests {
+		provider, err := New(name, Options{BaseURL: server.URL, Model: test.model, Retry: RetryPolicy{MaxAttempts: 1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		request := Request{Message: TextContent("Hi"), Sampling: Sampling{MaxTokens: 100}}
+		if _, err := provider.Send(context.Background(), request, nil); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if string(req[test.field]) != "100" {
+			t.Errorf("%s sent %s = %s, want 100", name, test.field, req[test.field])
+		}
+		for _, field := range []string{"max_tokens", "max_completion_tokens"} {
+			if _, ok := req[field]; ok && field != test.field {
+				t.Errorf("%s request also sets %s", name, field)
+			}
+		}
+	}
+}
+
+func TestO1MiniRequests(t *testing.T) {
+	var req openai.ChatCompletionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"}}]}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewOpenAI(Options{BaseURL: server.URL, Model: "o1-mini", Retry: RetryPolicy{MaxAttempts: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The model cannot stream, so the session renders the reply in one piece
+	if provider.SupportsStreaming() {
+		t.Error("o1-mini reported as streaming")
+	}
+
+	zero := 0.0
+	_, err = provider.Send(context.Background(), Request{
+		History: []Message{
+			{Role: "system", Content: TextContent("Be brief.")},
+			{Role: "user", Content: TextContent("Hi")},
+			{Role: "assistant", Content: TextContent("Hello.")},
+		},
+		Message:  TextContent("Bye"),
+		Sampling: Sampling{Temperature: &zero},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// System messages are folded into the first user message
+	var got []string
+	for _, msg := range req.Messages {
+		got = append(got, msg.Role+": "+msg.Content)
+	}
+	want := []string{"user: Be brief.\n\nHi", "assistant: Hello.", "user: Bye"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+	if req.Temperature != 0 {
+		t.Errorf("temperature = %v, want it left at the model's fixed value", req.Temperature)
+	}
+}
@@ -1,31 +1,135 @@
 package providers
 
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
 type Message struct {
-	Role    string
-	Content interface{}
+	Role string
+	// Content holds the message's parts. Assistant messages carry the tool
+	// calls they request as tool_call parts, and "tool" messages the
+	// results as tool_result parts.
+	Content Content
 }
 
 type Provider interface {
-	Send(history []Message, message interface{}, stream bool) (string, error)
+	// Send returns the reply along with any token usage the API reported
+	Send(ctx context.Context, request Request, sink StreamSink) (*Response, error)
 	SupportsStreaming() bool
+	// HandleRateLimiting wraps errors that are safe to retry in a *RetryableError
+	// and returns all others unchanged
 	HandleRateLimiting(error) error
 }
 
-func New(providerName, apiKey, model string) (Provider, error) {
-	switch providerName {
-	case "openai":
-		return NewOpenAI(apiKey, model)
-	case "anthropic":
-		return NewAnthropic(apiKey, model)
-	case "cohere-ai":
-		return NewCohere(apiKey, model)
-	case "@huggingface/inference", "huggingface":
-		return NewHuggingFace(apiKey, model)
-	case "langchain":
-		return NewLangchain(apiKey, model)
-	case "openrouter":
-		return NewOpenRouter(apiKey, model)
-	default:
-		return NewOpenAI(apiKey, model) // Default to OpenAI
+// ModelNamer is implemented by providers that report the model requests go
+// to, including the default they pick when none is configured
+type ModelNamer interface {
+	Model() string
+}
+
+// ModelLister is implemented by providers that can enumerate the models they serve
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// Options configures how a provider connects to its API
+type Options struct {
+	APIKey string
+	Model  string
+	// BaseURL overrides the provider's default endpoint, e.g. a self-hosted server
+	BaseURL string
+	// ChatTemplate selects how conversations are flattened for raw-prompt endpoints
+	ChatTemplate string
+	// Headers are sent with every request, e.g. for gateway authentication
+	Headers map[string]string
+	// Organization and Project scope requests for OpenAI-style APIs
+	Organization string
+	Project      string
+	// APIVersion is passed as the api-version query parameter when set
+	APIVersion string
+	// Deployment is the Azure OpenAI deployment requests are routed to
+	Deployment string
+	// AllowUnknownModel skips the model catalog check for providers that validate names
+	AllowUnknownModel bool
+	// StreamUsage requests usage in streamed responses from OpenAI-style
+	// servers; OpenAI itself always reports it
+	StreamUsage bool
+	// Retry controls retries of rate limited and failed requests; the zero value uses DefaultRetryPolicy
+	Retry RetryPolicy
+}
+
+// Constructor builds a provider from its options
+type Constructor func(opts Options) (Provider, error)
+
+type registration struct {
+	name        string
+	aliases     []string
+	constructor Constructor
+}
+
+// Registry stores all available providers keyed by name and alias
+var registry = make(map[string]*registration)
+
+// Register makes a provider available to New under its name and aliases.
+// It panics if a name is registered twice, as that is a programming error.
+func Register(name string, aliases []string, constructor Constructor) {
+	reg := &registration{name: name, aliases: aliases, constructor: constructor}
+	for _, key := range append([]string{name}, aliases...) {
+		if _, exists := registry[key]; exists {
+			panic(fmt.Sprintf("providers: provider %q registered twice", key))
+		}
+		registry[key] = reg
+	}
+}
+
+// Names returns the canonical names of all registered providers.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for key, reg := range registry {
+		if key == reg.name {
+			names = append(names, reg.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CanonicalName resolves an alias to the provider's registered name. Unknown
+// names are returned unchanged.
+func CanonicalName(providerName string) string {
+	if reg, exists := registry[providerName]; exists {
+		return reg.name
+	}
+	return providerName
+}
+
+// apiKeyEnv names the environment variable holding each provider's own API
+// key. Providers missing from it need no key.
+var apiKeyEnv = map[string]string{
+	"openai":       "OPENAI_API_KEY",
+	"anthropic":    "ANTHROPIC_API_KEY",
+	"cohere-ai":    "COHERE_API_KEY",
+	"gemini":       "GEMINI_API_KEY",
+	"openrouter":   "OPENROUTER_API_KEY",
+	"huggingface":  "HF_TOKEN",
+	"azure-openai": "AZURE_OPENAI_API_KEY",
+}
+
+// APIKeyEnv returns the environment variable holding the provider's own API
+// key, for reaching a provider other than the configured one, or "" if the
+// provider needs no key.
+func APIKeyEnv(providerName string) string {
+	return apiKeyEnv[CanonicalName(providerName)]
+}
+
+// New constructs the provider registered under providerName or one of its aliases.
+func New(providerName string, opts Options) (Provider, error) {
+	reg, exists := registry[providerName]
+	if !exists {
+		return nil, fmt.Errorf("unknown provider: %q. Known providers are: %s", providerName, strings.Join(Names(), ", "))
 	}
+	return reg.constructor(opts)
 }
@@ -0,0 +1,58 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestProvidersNameTheirDefaultModel(t *testing.T) {
+	tests := map[string]string{
+		"openai":      DefaultModel,
+		"anthropic":   anthropicDefaultModel,
+		"cohere":      cohereDefaultModel,
+		"gemini":      geminiDefaultModel,
+		"huggingface": huggingFaceDefaultModel,
+		"ollama":      ollamaDefaultModel,
+	}
+	for name, want := range tests {
+		provider, err := New(name, Options{})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		namer, ok := provider.(ModelNamer)
+		if !ok {
+			t.Fatalf("%s does not report its model", name)
+		}
+		if got := namer.Model(); got != want {
+			t.Errorf("%s model = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCatalogToolsMatchProviders(t *testing.T) {
+	// The request is cancelled, so only a provider rejecting tools up front
+	// fails with anything other than the cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	request := Request{
+		Message: TextContent("What is the weather?"),
+		Tools:   []Tool{{Name: "get_weather", Parameters: json.RawMessage(`{"type":"object"}`)}},
+	}
+
+	for provider, models := range catalog {
+		for _, model := range models {
+			if !model.Tools {
+				continue
+			}
+			p, err := New(provider, Options{APIKey: "test", Model: model.Name, Retry: RetryPolicy{MaxAttempts: 1}})
+			if err != nil {
+				t.Fatalf("%s %s: %v", provider, model.Name, err)
+			}
+			if _, err := p.Send(ctx, request, nil); err != nil && strings.Contains(err.Error(), "tool calling is not supported") {
+				t.Errorf("%s %s is listed with tools, but the provider rejects them", provider, model.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package providers
+
+import "encoding/json"
+
+// Request is a single call to a provider
+type Request struct {
+	// History is the conversation so far and Message the new user input. A
+	// nil Message continues from the end of the history, e.g. after tool results.
+	History  []Message
+	Message  Content
+	Sampling Sampling
+	// Tools are the functions the model may call
+	Tools []Tool
+	// ResponseFormat requests a JSON response; nil allows free text
+	ResponseFormat *ResponseFormat
+}
+
+// ResponseFormat asks for a JSON response. Providers that can constrain
+// output to a schema use Schema; the others only switch on JSON mode, and
+// the caller is expected to describe the schema in the prompt and validate.
+type ResponseFormat struct {
+	Schema json.RawMessage
+}
+
+// Sampling holds the generation parameters for a request. Nil and zero
+// fields are left out so the provider's defaults apply; Temperature and
+// Seed are pointers so that 0 can be requested explicitly.
+type Sampling struct {
+	Temperature *float64 `json:"TEMPERATURE,omitempty" yaml:"TEMPERATURE,omitempty"`
+	TopP        *float64 `json:"TOP_P,omitempty" yaml:"TOP_P,omitempty"`
+	Seed        *int     `json:"SEED,omitempty" yaml:"SEED,omitempty"`
+	// MaxTokens caps the tokens generated for the response
+	MaxTokens int      `json:"MAX_TOKENS,omitempty" yaml:"MAX_TOKENS,omitempty"`
+	Stop      []string `json:"STOP,omitempty" yaml:"STOP,omitempty"`
+}
+
+// Merge returns s with every field that is set in override replaced.
+func (s Sampling) Merge(override Sampling) Sampling {
+	if override.Temperature != nil {
+		s.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		s.TopP = override.TopP
+	}
+	if override.Seed != nil {
+		s.Seed = override.Seed
+	}
+	if override.MaxTokens > 0 {
+		s.MaxTokens = override.MaxTokens
+	}
+	if len(override.Stop) > 0 {
+		s.Stop = override.Stop
+	}
+	return s
+}
+
+// IsZero reports whether no parameters are set.
+func (s Sampling) IsZero() bool {
+	return s.Temperature == nil && s.TopP == nil && s.Seed == nil && s.MaxTokens <= 0 && len(s.Stop) == 0
+}
@@ -0,0 +1,191 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// RetryPolicy controls how rate limited and failed requests are retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first; 1 disables retries
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy is used when no retry settings are configured
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// RetryableError marks an error as safe to retry. HandleRateLimiting
+// implementations return it for rate limits, server errors and dropped
+// connections, and return any other error unchanged.
+type RetryableError struct {
+	Err error
+	// RetryAfter is the delay requested by the server, if any
+	RetryAfter time.Duration
+}
+
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// classifyError is the shared HandleRateLimiting behaviour: 429 and 5xx
+// responses and connection resets are retryable, everything else is not.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var retryable *RetryableError
+	if errors.As(err, &retryable) {
+		return err
+	}
+
+	var apiErr *APIError
+	var openaiErr *openai.APIError
+	var requestErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		if isRetryableStatus(apiErr.StatusCode) {
+			return &RetryableError{Err: err, RetryAfter: parseRetryAfter(apiErr.Header)}
+		}
+	case errors.As(err, &openaiErr):
+		if isRetryableStatus(openaiErr.HTTPStatusCode) {
+			return &RetryableError{Err: err}
+		}
+	case errors.As(err, &requestErr):
+		if isRetryableStatus(requestErr.HTTPStatusCode) {
+			return &RetryableError{Err: err}
+		}
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, io.ErrUnexpectedEOF):
+		return &RetryableError{Err: err}
+	default:
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return &RetryableError{Err: err}
+		}
+	}
+	return err
+}
+
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// parseRetryAfter reads the Retry-After header, given either in seconds or as an HTTP date.
+func parseRetryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// backoff returns the delay before the given retry (1-based), honoring the
+// server's Retry-After and otherwise using jittered exponential backoff.
+// Callers do not retry when Retry-After exceeds MaxDelay.
+func (p RetryPolicy) backoff(retry int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+
+	delay := p.BaseDelay << (retry - 1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	// Equal jitter keeps at least half the delay while spreading out clients
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryTransport retries requests whose response or error is classified as
+// retryable. Only the initial response is retried, so a stream that has
+// started delivering output is never replayed.
+type retryTransport struct {
+	base     http.RoundTripper
+	policy   RetryPolicy
+	classify func(error) error
+}
+
+// newRetryClient returns an HTTP client that retries according to policy,
+// using classify (normally the provider's HandleRateLimiting) to decide.
+func newRetryClient(base http.RoundTripper, policy RetryPolicy, classify func(error) error) *http.Client {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if policy.MaxAttempts == 0 {
+		policy = DefaultRetryPolicy
+	}
+	return &http.Client{Transport: &retryTransport{base: base, policy: policy, classify: classify}}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+
+		failure := err
+		if err == nil {
+			if !isRetryableStatus(resp.StatusCode) {
+				return resp, nil
+			}
+			failure = &APIError{StatusCode: resp.StatusCode, Header: resp.Header}
+		}
+
+		// A server asking to wait longer than MaxDelay is not waited for, so
+		// the CLI fails with the rate limit error instead of hanging
+		var retryable *RetryableError
+		canReplay := req.Body == nil || req.GetBody != nil
+		if !errors.As(t.classify(failure), &retryable) || attempt >= t.policy.MaxAttempts || !canReplay || retryable.RetryAfter > t.policy.MaxDelay {
+			return resp, err
+		}
+
+		// Discard the failed response so the connection can be reused
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(t.policy.backoff(attempt, retryable.RetryAfter))
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt, req.Context().Err())
+		case <-timer.C:
+		}
+	}
+}
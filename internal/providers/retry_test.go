@@ -0,0 +1,130 @@
+package providers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastRetries keeps tests quick while still exercising the backoff
+var fastRetries = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Second}
+
+// newFlakyServer answers with the given statuses in turn, then 200 once they
+// run out, and counts the requests it receives
+func newFlakyServer(t *testing.T, header http.Header, statuses ...int) (*httptest.Server, *int32) {
+	t.Helper()
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		if body, _ := io.ReadAll(r.Body); string(body) != "payload" {
+			t.Errorf("request %d body = %q, want it replayed", n, body)
+		}
+		if int(n) <= len(statuses) {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func post(t *testing.T, client *http.Client, url string) *http.Response {
+	t.Helper()
+	resp, err := client.Post(url, "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestRetryServerErrors(t *testing.T) {
+	server, requests := newFlakyServer(t, nil, http.StatusServiceUnavailable, http.StatusBadGateway)
+	client := newRetryClient(nil, fastRetries, classifyError)
+
+	resp := post(t, client, server.URL)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if *requests != 3 {
+		t.Errorf("requests = %d, want 3", *requests)
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	server, requests := newFlakyServer(t, nil, 500, 500, 500, 500)
+	client := newRetryClient(nil, fastRetries, classifyError)
+
+	resp := post(t, client, server.URL)
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("status = %d, want the last 500", resp.StatusCode)
+	}
+	if *requests != int32(fastRetries.MaxAttempts) {
+		t.Errorf("requests = %d, want %d", *requests, fastRetries.MaxAttempts)
+	}
+}
+
+func TestNoRetryOnClientErrors(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound} {
+		server, requests := newFlakyServer(t, nil, status)
+		client := newRetryClient(nil, fastRetries, classifyError)
+
+		resp := post(t, client, server.URL)
+		if resp.StatusCode != status || *requests != 1 {
+			t.Errorf("status %d: got %d after %d requests, want no retry", status, resp.StatusCode, *requests)
+		}
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	server, requests := newFlakyServer(t, http.Header{"Retry-After": {"1"}}, http.StatusTooManyRequests)
+	client := newRetryClient(nil, fastRetries, classifyError)
+
+	start := time.Now()
+	resp := post(t, client, server.URL)
+	if resp.StatusCode != http.StatusOK || *requests != 2 {
+		t.Errorf("got %d after %d requests, want 200 after 2", resp.StatusCode, *requests)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, before Retry-After", elapsed)
+	}
+}
+
+func TestRetryAfterBeyondMaxDelayFails(t *testing.T) {
+	server, requests := newFlakyServer(t, http.Header{"Retry-After": {"3600"}}, http.StatusTooManyRequests)
+	client := newRetryClient(nil, fastRetries, classifyError)
+
+	start := time.Now()
+	resp := post(t, client, server.URL)
+	if resp.StatusCode != http.StatusTooManyRequests || *requests != 1 {
+		t.Errorf("got %d after %d requests, want the 429 without a retry", resp.StatusCode, *requests)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("waited %v for a Retry-After beyond MaxDelay", elapsed)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	tests := map[string]time.Duration{
+		"":     0,
+		"5":    5 * time.Second,
+		"-1":   0,
+		"soon": 0,
+		date:   time.Minute,
+	}
+	for value, want := range tests {
+		got := parseRetryAfter(http.Header{"Retry-After": {value}})
+		if got > want || got < want-2*time.Second {
+			t.Errorf("parseRetryAfter(%q) = %v, want about %v", value, got, want)
+		}
+	}
+}
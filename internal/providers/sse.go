@@ -0,0 +1,68 @@
+package providers
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// sseEvent is a single server-sent event as defined by the text/event-stream format.
+type sseEvent struct {
+	Event string
+	Data  string
+}
+
+// sseReader decodes a text/event-stream body into events.
+type sseReader struct {
+	scanner *bufio.Scanner
+}
+
+func newSSEReader(r io.Reader) *sseReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	return &sseReader{scanner: scanner}
+}
+
+// Next returns the next event in the stream, or io.EOF once it is exhausted.
+func (r *sseReader) Next() (sseEvent, error) {
+	var event sseEvent
+	var data []string
+
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+
+		// A blank line dispatches the event collected so far
+		if line == "" {
+			if event.Event == "" && len(data) == 0 {
+				continue
+			}
+			event.Data = strings.Join(data, "\n")
+			return event, nil
+		}
+
+		// Lines starting with a colon are comments (often keep-alives)
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return sseEvent{}, err
+	}
+
+	// Some servers close the connection without a trailing blank line
+	if event.Event != "" || len(data) > 0 {
+		event.Data = strings.Join(data, "\n")
+		return event, nil
+	}
+	return sseEvent{}, io.EOF
+}
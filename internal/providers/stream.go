@@ -0,0 +1,98 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// EventType identifies the kind of a stream event
+type EventType string
+
+const (
+	EventTextDelta     EventType = "text_delta"
+	EventToolCallDelta EventType = "tool_call_delta"
+	EventUsage         EventType = "usage"
+	EventDone          EventType = "done"
+	EventError         EventType = "error"
+)
+
+// StreamEvent is emitted by providers as a streamed response arrives
+type StreamEvent struct {
+	Type     EventType
+	Text     string
+	ToolCall *ToolCallDelta
+	Usage    *Usage
+	Err      error
+}
+
+// ToolCallDelta is a fragment of a tool call; fragments with the same Index
+// belong to the same call and their Arguments concatenate into JSON
+type ToolCallDelta struct {
+	Index     int
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// StreamSink receives stream events. Passing a nil sink to Provider.Send
+// requests a non-streaming response.
+type StreamSink func(StreamEvent)
+
+func (s StreamSink) text(text string) {
+	if s != nil && text != "" {
+		s(StreamEvent{Type: EventTextDelta, Text: text})
+	}
+}
+
+// usage reports the token counts once the provider sends them
+func (s StreamSink) usage(usage Usage) {
+	if s != nil && (usage.PromptTokens > 0 || usage.CompletionTokens > 0) {
+		s(StreamEvent{Type: EventUsage, Usage: &usage})
+	}
+}
+
+func (s StreamSink) toolCall(delta ToolCallDelta) {
+	if s != nil {
+		s(StreamEvent{Type: EventToolCallDelta, ToolCall: &delta})
+	}
+}
+
+func (s StreamSink) done() {
+	if s != nil {
+		s(StreamEvent{Type: EventDone})
+	}
+}
+
+// fail reports a stream that ended early, preferring cancellation or a
+// timeout over the transport error it caused, and returns the error.
+func (s StreamSink) fail(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
+	if s != nil {
+		s(StreamEvent{Type: EventError, Err: err})
+	}
+	return err
+}
+
+// WriterSink renders text deltas to w, ending the output with a newline once
+// the stream finishes or fails part way through a line.
+func WriterSink(w io.Writer) StreamSink {
+	pending := false
+	return func(event StreamEvent) {
+		switch event.Type {
+		case EventTextDelta:
+			if event.Text == "" {
+				return
+			}
+			fmt.Fprint(w, event.Text)
+			pending = event.Text[len(event.Text)-1] != '\n'
+		case EventDone, EventError:
+			if pending {
+				fmt.Fprintln(w)
+				pending = false
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriterSinkEndsTheLine(t *testing.T) {
+	for name, test := range map[string]struct {
+		events []StreamEvent
+		want   string
+	}{
+		"done": {
+			events: []StreamEvent{{Type: EventTextDelta, Text: "Hello"}, {Type: EventTextDelta, Text: " world"}, {Type: EventDone}},
+			want:   "Hello world\n",
+		},
+		"already ended": {
+			events: []StreamEvent{{Type: EventTextDelta, Text: "Hello\n"}, {Type: EventDone}},
+			want:   "Hello\n",
+		},
+		"failed part way": {
+			events: []StreamEvent{{Type: EventTextDelta, Text: "Hel"}, {Type: EventError, Err: errors.New("reset")}},
+			want:   "Hel\n",
+		},
+		"usage and tool calls print nothing": {
+			events: []StreamEvent{{Type: EventUsage, Usage: &Usage{PromptTokens: 1}}, {Type: EventToolCallDelta, ToolCall: &ToolCallDelta{Name: "f"}}, {Type: EventDone}},
+			want:   "",
+		},
+	} {
+		var out strings.Builder
+		sink := WriterSink(&out)
+		for _, event := range test.events {
+			sink(event)
+		}
+		if out.String() != test.want {
+			t.Errorf("%s: wrote %q, want %q", name, out.String(), test.want)
+		}
+	}
+}
+
+func TestSinkFailPrefersTheContextError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var reported error
+	sink := StreamSink(func(event StreamEvent) {
+		if event.Type == EventError {
+			reported = event.Err
+		}
+	})
+	err := sink.fail(ctx, errors.New("connection reset"))
+	if !errors.Is(err, context.Canceled) || !errors.Is(reported, context.Canceled) {
+		t.Errorf("returned %v and reported %v, want the cancellation", err, reported)
+	}
+
+	// A nil sink only returns the error
+	if err := StreamSink(nil).fail(context.Background(), errors.New("eof")); err == nil || err.Error() != "eof" {
+		t.Errorf("nil sink returned %v, want the stream error", err)
+	}
+}
@@ -0,0 +1,154 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// chatTemplate flattens a conversation into a single raw prompt for
+// endpoints that only accept text completion requests.
+type chatTemplate struct {
+	render func(messages []templateMessage) string
+	stop   []string
+}
+
+type templateMessage struct {
+	Role    string
+	Content string
+}
+
+const defaultChatTemplate = "chatml"
+
+// modelTemplates picks the template for models whose name gives away their
+// prompt format, checked in order against the lowercased model name
+var modelTemplates = []struct {
+	match    string
+	template string
+}{
+	{"zephyr", "zephyr"},
+	{"llama-3", "llama3"},
+	{"llama3", "llama3"},
+	{"mistral", "mistral"},
+	{"mixtral", "mistral"},
+}
+
+var chatTemplates = map[string]chatTemplate{
+	"chatml": {
+		render: func(messages []templateMessage) string {
+			var b strings.Builder
+			for _, msg := range messages {
+				fmt.Fprintf(&b, "<|im_start|>%s\n%s<|im_end|>\n", msg.Role, msg.Content)
+			}
+			b.WriteString("<|im_start|>assistant\n")
+			return b.String()
+		},
+		stop: []string{"<|im_end|>"},
+	},
+	"llama3": {
+		render: func(messages []templateMessage) string {
+			var b strings.Builder
+			b.WriteString("<|begin_of_text|>")
+			for _, msg := range messages {
+				fmt.Fprintf(&b, "<|start_header_id|>%s<|end_header_id|>\n\n%s<|eot_id|>", msg.Role, msg.Content)
+			}
+			b.WriteString("<|start_header_id|>assistant<|end_header_id|>\n\n")
+			return b.String()
+		},
+		stop: []string{"<|eot_id|>"},
+	},
+	"mistral": {
+		// Mistral has no system role, so system text is prepended to the next user turn
+		render: func(messages []templateMessage) string {
+			var b strings.Builder
+			var system []string
+			b.WriteString("<s>")
+			for _, msg := range messages {
+				switch msg.Role {
+				case "system":
+					system = append(system, msg.Content)
+				case "assistant":
+					fmt.Fprintf(&b, "%s</s>", msg.Content)
+				default:
+					content := msg.Content
+					if len(system) > 0 {
+						content = strings.Join(system, "\n\n") + "\n\n" + content
+						system = nil
+					}
+					fmt.Fprintf(&b, "[INST] %s [/INST]", content)
+				}
+			}
+			return b.String()
+		},
+		stop: []string{"</s>"},
+	},
+	"zephyr": {
+		render: func(messages []templateMessage) string {
+			var b strings.Builder
+			for _, msg := range messages {
+				fmt.Fprintf(&b, "<|%s|>\n%s</s>\n", msg.Role, msg.Content)
+			}
+			b.WriteString("<|assistant|>\n")
+			return b.String()
+		},
+		stop: []string{"</s>"},
+	},
+	"plain": {
+		render: func(messages []templateMessage) string {
+			var b strings.Builder
+			for _, msg := range messages {
+				fmt.Fprintf(&b, "%s%s: %s\n\n", strings.ToUpper(msg.Role[:1]), msg.Role[1:], msg.Content)
+			}
+			b.WriteString("Assistant:")
+			return b.String()
+		},
+		stop: []string{"\nUser:"},
+	},
+}
+
+// templateForModel returns the name of the template matching the model's
+// prompt format, or ChatML when the name does not tell
+func templateForModel(model string) string {
+	model = strings.ToLower(model)
+	for _, m := range modelTemplates {
+		if strings.Contains(model, m.match) {
+			return m.template
+		}
+	}
+	return defaultChatTemplate
+}
+
+// getChatTemplate looks up a template by name. Without a name it follows the
+// model, falling back to ChatML.
+func getChatTemplate(name, model string) (chatTemplate, error) {
+	if name == "" {
+		name = templateForModel(model)
+	}
+	tmpl, ok := chatTemplates[name]
+	if !ok {
+		names := make([]string, 0, len(chatTemplates))
+		for n := range chatTemplates {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return chatTemplate{}, fmt.Errorf("unknown chat template: %s. Valid templates are: %s", name, strings.Join(names, ", "))
+	}
+	return tmpl, nil
+}
+
+// toTemplateMessages normalises history plus the new message, if any, into
+// plain text turns.
+func toTemplateMessages(history []Message, message Content) []templateMessage {
+	messages := make([]templateMessage, 0, len(history)+1)
+	for _, msg := range history {
+		role := msg.Role
+		if role != "system" && role != "assistant" {
+			role = "user"
+		}
+		messages = append(messages, templateMessage{Role: role, Content: msg.Content.Text()})
+	}
+	if message != nil {
+		messages = append(messages, templateMessage{Role: "user", Content: message.Text()})
+	}
+	return messages
+}
@@ -0,0 +1,97 @@
+package providers
+
+import (
+	"math"
+	"unicode/utf8"
+)
+
+// Tokenizer estimates token counts for a provider's models from the length
+// of the text. It is only an approximation, used for budgets, fitting the
+// context window and counts the provider does not report.
+type Tokenizer struct {
+	charsPerToken float64
+}
+
+// defaultCharsPerToken is a rough average for English text and code across
+// the tokenizers in use
+const defaultCharsPerToken = 4
+
+// charsPerToken holds the providers whose models typically tokenize text
+// more finely than the default: Claude, and the Llama and Mistral family
+// models usually served by Ollama, llama.cpp and TGI.
+var charsPerToken = map[string]float64{
+	"anthropic":   3.5,
+	"huggingface": 3.5,
+	"llama.cpp":   3.5,
+	"ollama":      3.5,
+}
+
+// imageTokens is roughly what a detailed image of about 1024 pixels square
+// costs on the vision APIs that publish a formula
+const imageTokens = 765
+
+// perMessageTokens allows for the role and framing of each message
+const perMessageTokens = 4
+
+// TokenizerFor returns the estimate used for a provider's models
+func TokenizerFor(provider string) Tokenizer {
+	if ratio, ok := charsPerToken[CanonicalName(provider)]; ok {
+		return Tokenizer{charsPerToken: ratio}
+	}
+	return Tokenizer{charsPerToken: defaultCharsPerToken}
+}
+
+func (t Tokenizer) ratio() float64 {
+	if t.charsPerToken <= 0 {
+		return defaultCharsPerToken
+	}
+	return t.charsPerToken
+}
+
+// Tokens approximates the number of tokens in text.
+func (t Tokenizer) Tokens(text string) int {
+	return int(math.Ceil(float64(utf8.RuneCountInString(text)) / t.ratio()))
+}
+
+// Chars approximates how many characters make up the given number of tokens.
+func (t Tokenizer) Chars(tokens int) int {
+	return int(float64(tokens) * t.ratio())
+}
+
+// ContentTokens approximates the tokens in a message's content, counting
+// tool call arguments and a flat rate per image.
+func (t Tokenizer) ContentTokens(content Content) int {
+	tokens := t.Tokens(content.Text())
+	for _, call := range content.ToolCalls() {
+		tokens += t.Tokens(call.Name + call.Arguments)
+	}
+	return tokens + imageTokens*len(content.Parts(PartImage))
+}
+
+// MessageTokens approximates the tokens of a single message, including its framing.
+func (t Tokenizer) MessageTokens(msg Message) int {
+	return t.ContentTokens(msg.Content) + perMessageTokens
+}
+
+// PromptTokens approximates the prompt tokens for history plus message.
+func (t Tokenizer) PromptTokens(history []Message, message Content) int {
+	tokens := t.ContentTokens(message) + perMessageTokens
+	for _, msg := range history {
+		tokens += t.MessageTokens(msg)
+	}
+	return tokens
+}
+
+// EstimateUsage fills in whichever counts the provider left out, marking
+// the usage as estimated if it had to.
+func (t Tokenizer) EstimateUsage(usage Usage, history []Message, message Content, response string) Usage {
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = t.PromptTokens(history, message)
+		usage.Estimated = true
+	}
+	if usage.CompletionTokens == 0 && response != "" {
+		usage.CompletionTokens = t.Tokens(response)
+		usage.Estimated = true
+	}
+	return usage
+}
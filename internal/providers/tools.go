@@ -0,0 +1,58 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Tool describes a function the model may call
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON Schema of the arguments object
+	Parameters json.RawMessage
+}
+
+// ToolCall is a model's request to run a tool
+type ToolCall struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Arguments is the JSON encoded arguments object
+	Arguments string `json:"arguments"`
+}
+
+// toolsUnsupported is returned by providers that cannot offer tools to the model
+func toolsUnsupported(provider string, request Request) error {
+	if len(request.Tools) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s: tool calling is not supported by this provider", provider)
+}
+
+// toolCallBuilder assembles tool calls from streamed fragments
+type toolCallBuilder struct {
+	calls []ToolCall
+}
+
+func (b *toolCallBuilder) add(delta ToolCallDelta) {
+	for len(b.calls) <= delta.Index {
+		b.calls = append(b.calls, ToolCall{})
+	}
+	call := &b.calls[delta.Index]
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if delta.Name != "" {
+		call.Name = delta.Name
+	}
+	call.Arguments += delta.Arguments
+}
+
+// schemaOrEmpty returns the tool's parameters, defaulting to an object with
+// no properties since the APIs require a schema
+func (t Tool) schemaOrEmpty() json.RawMessage {
+	if len(t.Parameters) == 0 {
+		return json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+	return t.Parameters
+}
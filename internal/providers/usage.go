@@ -0,0 +1,48 @@
+package providers
+
+import "encoding/json"
+
+// Response is a provider's reply together with the tokens it consumed
+type Response struct {
+	Content string
+	// ToolCalls are the tools the model asked to run, in order
+	ToolCalls []ToolCall
+	Usage     Usage
+}
+
+// Usage reports the tokens consumed by a request
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	// Estimated is set when the provider did not report some of the counts
+	// and they were approximated from the text instead
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+// TotalTokens returns the prompt and completion tokens combined.
+func (u Usage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// Cost returns the estimated cost in USD of usage on a provider's model,
+// and false when the catalog has no entry for the model.
+func Cost(provider, model string, usage Usage) (float64, bool) {
+	info, ok := LookupModel(provider, model)
+	if !ok {
+		return 0, false
+	}
+	cost := float64(usage.PromptTokens)*info.InputPrice + float64(usage.CompletionTokens)*info.OutputPrice
+	return cost / 1e6, true
+}
+
+// usageCount accepts token counts that some APIs send as floats.
+type usageCount int
+
+func (c *usageCount) UnmarshalJSON(data []byte) error {
+	var value float64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*c = usageCount(value)
+	return nil
+}
@@ -0,0 +1,360 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a parsed JSON Schema. It supports the keywords used to describe
+// structured output: type, enum, const, properties, required,
+// additionalProperties, items, minItems, maxItems, minLength, maxLength,
+// pattern, minimum, maximum, allOf, anyOf, oneOf and local $ref pointers.
+type Schema struct {
+	raw  json.RawMessage
+	root interface{}
+}
+
+// Load reads a schema from a JSON file
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Parse parses a schema from JSON
+func Parse(data []byte) (*Schema, error) {
+	var root interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid JSON Schema: %w", err)
+	}
+	switch root.(type) {
+	case map[string]interface{}, bool:
+	default:
+		return nil, fmt.Errorf("invalid JSON Schema: must be an object or a boolean")
+	}
+	return &Schema{raw: json.RawMessage(data), root: root}, nil
+}
+
+// Raw returns the schema as JSON
+func (s *Schema) Raw() json.RawMessage {
+	return s.raw
+}
+
+// Validate checks a decoded JSON value against the schema and returns a
+// description of every violation, each prefixed with its path.
+func (s *Schema) Validate(value interface{}) []string {
+	v := &validator{root: s.root}
+	v.validate(s.root, value, "$")
+	return v.errors
+}
+
+type validator struct {
+	root   interface{}
+	errors []string
+	// depth guards against $ref cycles
+	depth int
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	v.errors = append(v.errors, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) validate(schema, value interface{}, path string) {
+	node, ok := schema.(map[string]interface{})
+	if !ok {
+		if allowed, isBool := schema.(bool); isBool && !allowed {
+			v.fail(path, "no value is allowed here")
+		}
+		return
+	}
+
+	if ref, ok := node["$ref"].(string); ok {
+		target, err := v.resolve(ref)
+		if err != nil {
+			v.fail(path, "%v", err)
+			return
+		}
+		if v.depth > 64 {
+			v.fail(path, "schema reference %s is too deeply nested", ref)
+			return
+		}
+		v.depth++
+		v.validate(target, value, path)
+		v.depth--
+	}
+
+	if types, ok := node["type"]; ok && !matchesType(types, value) {
+		v.fail(path, "expected %s, got %s", describeTypes(types), typeName(value))
+		// The remaining keywords assume the right type
+		return
+	}
+
+	if enum, ok := node["enum"].([]interface{}); ok {
+		found := false
+		for _, option := range enum {
+			if reflect.DeepEqual(option, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "must be one of %s", compact(enum))
+		}
+	}
+	if constant, ok := node["const"]; ok && !reflect.DeepEqual(constant, value) {
+		v.fail(path, "must be %s", compact(constant))
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		v.validateObject(node, value, path)
+	case []interface{}:
+		v.validateArray(node, value, path)
+	case string:
+		v.validateString(node, value, path)
+	case float64:
+		v.validateNumber(node, value, path)
+	}
+
+	if all, ok := node["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			v.validate(sub, value, path)
+		}
+	}
+	if anyOf, ok := node["anyOf"].([]interface{}); ok && v.countMatches(anyOf, value, path) == 0 {
+		v.fail(path, "does not match any of the allowed schemas")
+	}
+	if oneOf, ok := node["oneOf"].([]interface{}); ok {
+		if matches := v.countMatches(oneOf, value, path); matches != 1 {
+			v.fail(path, "must match exactly one of the allowed schemas, matched %d", matches)
+		}
+	}
+}
+
+func (v *validator) validateObject(node map[string]interface{}, value map[string]interface{}, path string) {
+	if required, ok := node["required"].([]interface{}); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, present := value[key]; !present {
+					v.fail(path, "missing required property %q", key)
+				}
+			}
+		}
+	}
+
+	properties, _ := node["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		childPath := path + "." + key
+		if sub, ok := properties[key]; ok {
+			v.validate(sub, value[key], childPath)
+			continue
+		}
+		switch additional := node["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.fail(path, "unexpected property %q", key)
+			}
+		case map[string]interface{}:
+			v.validate(additional, value[key], childPath)
+		}
+	}
+}
+
+func (v *validator) validateArray(node map[string]interface{}, value []interface{}, path string) {
+	if min, ok := number(node["minItems"]); ok && float64(len(value)) < min {
+		v.fail(path, "must have at least %v items, has %d", min, len(value))
+	}
+	if max, ok := number(node["maxItems"]); ok && float64(len(value)) > max {
+		v.fail(path, "must have at most %v items, has %d", max, len(value))
+	}
+	if items, ok := node["items"]; ok {
+		for i, item := range value {
+			v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func (v *validator) validateString(node map[string]interface{}, value, path string) {
+	length := float64(utf8.RuneCountInString(value))
+	if min, ok := number(node["minLength"]); ok && length < min {
+		v.fail(path, "must be at least %v characters", min)
+	}
+	if max, ok := number(node["maxLength"]); ok && length > max {
+		v.fail(path, "must be at most %v characters", max)
+	}
+	if pattern, ok := node["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			v.fail(path, "schema pattern %q is invalid: %v", pattern, err)
+		} else if !re.MatchString(value) {
+			v.fail(path, "must match pattern %q", pattern)
+		}
+	}
+}
+
+func (v *validator) validateNumber(node map[string]interface{}, value float64, path string) {
+	if min, ok := number(node["minimum"]); ok && value < min {
+		v.fail(path, "must be at least %v", min)
+	}
+	if max, ok := number(node["maximum"]); ok && value > max {
+		v.fail(path, "must be at most %v", max)
+	}
+	if min, ok := number(node["exclusiveMinimum"]); ok && value <= min {
+		v.fail(path, "must be greater than %v", min)
+	}
+	if max, ok := number(node["exclusiveMaximum"]); ok && value >= max {
+		v.fail(path, "must be less than %v", max)
+	}
+}
+
+// countMatches reports how many of the schemas value satisfies
+func (v *validator) countMatches(schemas []interface{}, value interface{}, path string) int {
+	matches := 0
+	for _, sub := range schemas {
+		trial := &validator{root: v.root, depth: v.depth}
+		trial.validate(sub, value, path)
+		if len(trial.errors) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+// resolve follows a local JSON pointer such as #/$defs/item
+func (v *validator) resolve(ref string) (interface{}, error) {
+	if ref == "#" {
+		return v.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported schema reference %q: only local references are allowed", ref)
+	}
+
+	node := v.root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("schema reference %q not found", ref)
+		}
+		if node, ok = object[token]; !ok {
+			return nil, fmt.Errorf("schema reference %q not found", ref)
+		}
+	}
+	return node, nil
+}
+
+func matchesType(types, value interface{}) bool {
+	switch types := types.(type) {
+	case string:
+		return isType(types, value)
+	case []interface{}:
+		for _, t := range types {
+			if name, ok := t.(string); ok && isType(name, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func isType(name string, value interface{}) bool {
+	switch name {
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return typeName(value) == name
+	}
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func describeTypes(types interface{}) string {
+	if list, ok := types.([]interface{}); ok {
+		names := make([]string, len(list))
+		for i, t := range list {
+			names[i] = fmt.Sprint(t)
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(types)
+}
+
+func number(value interface{}) (float64, bool) {
+	n, ok := value.(float64)
+	return n, ok
+}
+
+func compact(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// ExtractJSON returns the JSON value in a model's response, removing the
+// Markdown code fence or surrounding prose models often add
+func ExtractJSON(text string) (string, interface{}, error) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+		text = strings.TrimSpace(text)
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err == nil {
+		return text, value, nil
+	}
+
+	// Fall back to the span from the first opening to the last closing bracket
+	start := strings.IndexAny(text, "{[")
+	end := strings.LastIndexAny(text, "}]")
+	if start >= 0 && end > start {
+		candidate := text[start : end+1]
+		if err := json.Unmarshal([]byte(candidate), &value); err == nil {
+			return candidate, value, nil
+		}
+	}
+
+	err := json.Unmarshal([]byte(text), &value)
+	return "", nil, fmt.Errorf("response is not valid JSON: %v", err)
+}
@@ -0,0 +1,103 @@
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		value  string
+		// errors are substrings of the expected errors, in order
+		errors []string
+	}{
+		{"type", `{"type":"string"}`, `"ok"`, nil},
+		{"wrong type", `{"type":"string"}`, `1`, []string{"$: expected string, got number"}},
+		{"type list", `{"type":["string","null"]}`, `null`, nil},
+		{"integer", `{"type":"integer"}`, `1.5`, []string{"expected integer, got number"}},
+		{"whole number is an integer", `{"type":"integer"}`, `2`, nil},
+		{"enum", `{"enum":["low","high"]}`, `"medium"`, []string{`must be one of ["low","high"]`}},
+		{"const", `{"const":{"v":1}}`, `{"v":1}`, nil},
+		{"wrong const", `{"const":1}`, `2`, []string{"must be 1"}},
+		{"required", `{"type":"object","required":["a","b"]}`, `{"a":1}`, []string{`missing required property "b"`}},
+		{"properties", `{"properties":{"a":{"type":"number"}}}`, `{"a":"x"}`, []string{"$.a: expected number, got string"}},
+		{"no additional properties", `{"properties":{"a":{}},"additionalProperties":false}`, `{"a":1,"b":2}`, []string{`unexpected property "b"`}},
+		{"additional property schema", `{"additionalProperties":{"type":"boolean"}}`, `{"x":true,"y":1}`, []string{"$.y: expected boolean"}},
+		{"items", `{"items":{"type":"string"}}`, `["a",2]`, []string{"$[1]: expected string"}},
+		{"min items", `{"minItems":2}`, `[1]`, []string{"at least 2 items"}},
+		{"max items", `{"maxItems":1}`, `[1,2]`, []string{"at most 1 items"}},
+		{"min length counts characters", `{"minLength":3}`, `"héé"`, nil},
+		{"min length", `{"minLength":3}`, `"ab"`, []string{"at least 3 characters"}},
+		{"max length", `{"maxLength":2}`, `"abc"`, []string{"at most 2 characters"}},
+		{"pattern", `{"pattern":"^[a-z]+$"}`, `"Abc"`, []string{"must match pattern"}},
+		{"invalid pattern", `{"pattern":"("}`, `"a"`, []string{"schema pattern"}},
+		{"minimum", `{"minimum":1}`, `0`, []string{"must be at least 1"}},
+		{"maximum", `{"maximum":1}`, `2`, []string{"must be at most 1"}},
+		{"exclusive minimum", `{"exclusiveMinimum":1}`, `1`, []string{"must be greater than 1"}},
+		{"exclusive maximum", `{"exclusiveMaximum":1}`, `1`, []string{"must be less than 1"}},
+		{"all of", `{"allOf":[{"minimum":1},{"maximum":3}]}`, `4`, []string{"must be at most 3"}},
+		{"any of", `{"anyOf":[{"type":"string"},{"type":"boolean"}]}`, `1`, []string{"does not match any"}},
+		{"any of matches", `{"anyOf":[{"type":"string"},{"type":"number"}]}`, `1`, nil},
+		{"one of matches two", `{"oneOf":[{"type":"number"},{"minimum":0}]}`, `1`, []string{"matched 2"}},
+		{"one of", `{"oneOf":[{"type":"number"},{"type":"string"}]}`, `1`, nil},
+		{"false schema", `{"properties":{"a":false}}`, `{"a":1}`, []string{"$.a: no value is allowed"}},
+		{"ref", `{"$defs":{"id":{"type":"integer"}},"properties":{"id":{"$ref":"#/$defs/id"}}}`, `{"id":"x"}`, []string{"$.id: expected integer"}},
+		{"missing ref", `{"$ref":"#/$defs/none"}`, `1`, []string{"not found"}},
+		{"remote ref", `{"$ref":"https://example.com/s.json"}`, `1`, []string{"only local references"}},
+		{"recursive ref", `{"properties":{"next":{"$ref":"#"}},"type":"object"}`, `{"next":{"next":{"next":1}}}`, []string{"$.next.next.next: expected object"}},
+		{"every error is reported", `{"required":["a"],"properties":{"b":{"type":"string"}}}`, `{"b":1}`, []string{`missing required property "a"`, "$.b: expected string"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := Parse([]byte(test.schema))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var value interface{}
+			if err := json.Unmarshal([]byte(test.value), &value); err != nil {
+				t.Fatal(err)
+			}
+
+			errors := s.Validate(value)
+			if len(errors) != len(test.errors) {
+				t.Fatalf("errors = %q, want %d matching %q", errors, len(test.errors), test.errors)
+			}
+			for i, want := range test.errors {
+				if !strings.Contains(errors[i], want) {
+					t.Errorf("error %d = %q, want it to contain %q", i, errors[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRejectsNonSchemas(t *testing.T) {
+	for _, data := range []string{`[1]`, `"string"`, `{`} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%s) succeeded", data)
+		}
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := map[string]string{
+		`{"a":1}`:                 `{"a":1}`,
+		"```json\n{\"a\":1}\n```": `{"a":1}`,
+		"```\n[1,2]\n```":         `[1,2]`,
+		"Here is the result:\n{\"a\":1}\nThanks!": `{"a":1}`,
+	}
+	for response, want := range tests {
+		text, _, err := ExtractJSON(response)
+		if err != nil || text != want {
+			t.Errorf("ExtractJSON(%q) = %q, %v, want %q", response, text, err, want)
+		}
+	}
+
+	if _, _, err := ExtractJSON("no JSON here"); err == nil {
+		t.Error("ExtractJSON found JSON in plain prose")
+	}
+}
@@ -0,0 +1,88 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Entry records the tokens and estimated cost of a single request
+type Entry struct {
+	Time             time.Time `json:"time"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	Action           string    `json:"action,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	// Cost is in USD and is zero when the model has no pricing in the catalog
+	Cost      float64 `json:"cost"`
+	Estimated bool    `json:"estimated,omitempty"`
+}
+
+// LedgerPath returns the location of the usage ledger, one JSON entry per line
+func LedgerPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ".gopilot_usage.jsonl"
+	}
+	return filepath.Join(homeDir, ".gopilot_usage.jsonl")
+}
+
+// Append adds an entry to the ledger
+func Append(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(LedgerPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Load reads every entry in the ledger, skipping lines it cannot parse. A
+// missing ledger is not an error.
+func Load() ([]Entry, error) {
+	f, err := os.Open(LedgerPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// CostSince returns the total cost of the ledger entries recorded at or after since
+func CostSince(since time.Time) (float64, error) {
+	entries, err := Load()
+	if err != nil {
+		return 0, err
+	}
+
+	var total float64
+	for _, entry := range entries {
+		if !entry.Time.Before(since) {
+			total += entry.Cost
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,49 @@
+package usage
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLedger(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if entries, err := Load(); err != nil || len(entries) != 0 {
+		t.Fatalf("Load without a ledger = %v, %v, want nothing", entries, err)
+	}
+
+	yesterday := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	now := time.Now().Truncate(time.Second)
+	for _, entry := range []Entry{
+		{Time: yesterday, Provider: "openai", Model: "gpt-4o", PromptTokens: 100, CompletionTokens: 10, Cost: 0.25},
+		{Time: now, Provider: "openai", Model: "gpt-4o", Action: "summary", PromptTokens: 200, CompletionTokens: 20, Cost: 0.5, Estimated: true},
+	} {
+		if err := Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A line torn by a crash or edited by hand is skipped
+	f, err := os.OpenFile(LedgerPath(), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{\"time\":\n")
+	f.Close()
+
+	entries, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[1].Action != "summary" || !entries[1].Estimated || !entries[1].Time.Equal(now) {
+		t.Errorf("entries = %+v, want the two appended", entries)
+	}
+
+	if cost, err := CostSince(now.Add(-time.Hour)); err != nil || cost != 0.5 {
+		t.Errorf("CostSince an hour ago = %v, %v, want 0.5", cost, err)
+	}
+	if cost, err := CostSince(time.Time{}); err != nil || cost != 0.75 {
+		t.Errorf("CostSince ever = %v, %v, want 0.75", cost, err)
+	}
+}